@@ -2,9 +2,8 @@ module break-nlss
 
 go 1.25.1
 
-require golang.org/x/crypto v0.44.0
-
 require (
-	github.com/joho/godotenv v1.5.1 // indirect
-	golang.org/x/sys v0.38.0 // indirect
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.44.0
+	golang.org/x/sys v0.38.0
 )
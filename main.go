@@ -2,16 +2,35 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
+	"break-nlss/pkg/audit"
+	"break-nlss/pkg/auth"
 	"break-nlss/pkg/config"
+	"break-nlss/pkg/crypto"
 	"break-nlss/pkg/nlss"
 	"break-nlss/pkg/rubix"
+	"break-nlss/pkg/simnode"
 	"break-nlss/pkg/storage"
+	"break-nlss/pkg/vault"
 
 	"github.com/joho/godotenv"
 )
@@ -27,13 +46,22 @@ func printUsage() {
 	fmt.Println("  break-nlss <command> [options]")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  transfer     - Transfer tokens to another DID")
-	fmt.Println("  balance      - Get account balance for a DID")
-	fmt.Println("  list-dids    - List all DIDs from the node")
-	fmt.Println("  export-dids  - Export DIDs with balance > 0 to a file")
-	fmt.Println("  generate-key - Generate a new EC key pair")
-	fmt.Println("  break-nlss   - Reconstruct private share from DID and public share")
-	fmt.Println("  help         - Show this help message")
+	fmt.Println("  transfer       - Transfer tokens to another DID (or: transfer prepare|sign|submit|status|recover)")
+	fmt.Println("  balance        - Get account balance for a DID")
+	fmt.Println("  list-dids      - List all DIDs from the node")
+	fmt.Println("  history        - Show the transactions a DID sent and received")
+	fmt.Println("  receipts       - Show transfers recorded in the local ledger")
+	fmt.Println("  export-dids    - Export DIDs with balance > 0 to a file")
+	fmt.Println("  generate-key   - Generate a new EC key pair")
+	fmt.Println("  break-nlss     - Reconstruct private share from DID and public share")
+	fmt.Println("  generate-nlss  - Generate a new DID image with public and private shares")
+	fmt.Println("  scan           - Discover DID folders under the NLSS node directory")
+	fmt.Println("  sign-message   - Sign a message or file with a DID's private share")
+	fmt.Println("  verify-message - Verify a signed message with the DID image and public share")
+	fmt.Println("  auth-server    - Serve challenge-response DID logins for internal services")
+	fmt.Println("  simulate-node  - Run a local Rubix node simulator with an in-memory ledger")
+	fmt.Println("  audit verify   - Check the signing audit log for edited or deleted entries")
+	fmt.Println("  help           - Show this help message")
 	fmt.Println()
 	fmt.Println("Environment Variables:")
 	fmt.Println("  RUBIX_NODE_URL   - Rubix node URL (default: localhost:20006)")
@@ -41,6 +69,8 @@ func printUsage() {
 	fmt.Println("  NLSS_BASE_PATH   - Base path for NLSS DID storage")
 	fmt.Println("  NLSS_NODE_NAME   - Node name for NLSS paths")
 	fmt.Println("  NLSS_OUTPUT_DIR  - Output directory for private shares (default: ./output)")
+	fmt.Println("  LEDGER_PATH      - Local transfer ledger (default: break-nlss/transfers.jsonl in the user config dir; off to disable)")
+	fmt.Println("  AUDIT_LOG_PATH   - Hash-chained log of signing operations (default: audit.jsonl; off to disable)")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  # Export DIDs with balance > 0 to file")
@@ -49,9 +79,29 @@ func printUsage() {
 	fmt.Println("  # Transfer tokens from file")
 	fmt.Println("  break-nlss transfer --from-file accounts.json --sender-index 0 --receiver bafybmi... --amount 10.5")
 	fmt.Println()
+	fmt.Println("  # Transfer with the private share kept offline")
+	fmt.Println("  break-nlss transfer prepare --receiver bafybmi... --amount 10.5 --output transfer.json")
+	fmt.Println("  break-nlss transfer sign --bundle transfer.json --output transfer.signed.json")
+	fmt.Println("  break-nlss transfer submit --bundle transfer.signed.json")
+	fmt.Println()
+	fmt.Println("  # Wait until the transfer is final and record the outcome")
+	fmt.Println("  break-nlss transfer --receiver bafybmi... --amount 1 --wait --result-file result.json")
+	fmt.Println("  break-nlss transfer status --result result.json")
+	fmt.Println()
+	fmt.Println("  # Finish or give up transfers interrupted by a crash")
+	fmt.Println("  break-nlss transfer recover")
+	fmt.Println("  break-nlss transfer recover --id 9c1e... --resume --in-memory")
+	fmt.Println()
 	fmt.Println("  # Get balance")
 	fmt.Println("  break-nlss balance --did bafybmi...")
 	fmt.Println()
+	fmt.Println("  # Transactions of a DID this month, as CSV")
+	fmt.Println("  break-nlss history --did bafybmi... --from 2025-11-01 --format csv --output history.csv")
+	fmt.Println()
+	fmt.Println("  # Receipts of failed or pending transfers, and the full record of one")
+	fmt.Println("  break-nlss receipts --state pending")
+	fmt.Println("  break-nlss receipts --request-id 4f1c...")
+	fmt.Println()
 	fmt.Println("  # Generate new keys")
 	fmt.Println("  break-nlss generate-key --output ./preset")
 	fmt.Println()
@@ -61,6 +111,69 @@ func printUsage() {
 	fmt.Println("  # Reconstruct private shares from multiple DIDs in file")
 	fmt.Println("  break-nlss break-nlss --did dids.txt")
 	fmt.Println()
+	fmt.Println("  # Reconstruct private shares for every DID found on the node")
+	fmt.Println("  break-nlss break-nlss --all --workers 8")
+	fmt.Println()
+	fmt.Println("  # Prove control of a DID off-chain")
+	fmt.Println("  break-nlss sign-message --did bafybmi... --message \"login to tools\" --output msg.sig.json")
+	fmt.Println("  break-nlss verify-message --signature msg.sig.json")
+	fmt.Println()
+	fmt.Println("  # Authenticate users by DID")
+	fmt.Println("  break-nlss auth-server --registry auth-registry.json --listen :8090")
+	fmt.Println()
+	fmt.Println("  # Test transfers without a network")
+	fmt.Println("  break-nlss simulate-node --scan --balance 100")
+	fmt.Println()
+	fmt.Println("  # Check that no signing record was edited or deleted")
+	fmt.Println("  break-nlss audit verify")
+	fmt.Println()
+	fmt.Println("  # Generate a reproducible DID image and shares for test fixtures")
+	fmt.Println("  break-nlss generate-nlss --output ./fixtures/did1 --seed fixture-1")
+	fmt.Println()
+}
+
+// Exit codes distinguishing node failures, for scripts wrapping the CLI.
+// Flag parsing errors exit with 2 (flag.ExitOnError).
+const (
+	exitFailure             = 1 // any other error
+	exitInsufficientBalance = 3
+	exitInvalidRequest      = 4 // invalid receiver or request rejected by the node
+	exitNotFound            = 5 // unknown DID or transfer request
+	exitUnauthorized        = 6
+	exitSignatureRejected   = 7 // rejected by the node or failed local verification
+	exitNodeUnreachable     = 8 // network error, timeout or node unavailable
+	exitMalformedResponse   = 9
+	exitTransferPending     = 10 // not final when --wait gave up, or still pending in transfer status
+	exitTransferFailed      = 11 // submitted, but the node did not finalize it
+	exitAuditTampered       = 12 // audit verify found edited or deleted entries
+	exitDuplicateTransfer   = 13 // an identical transfer was already made; see --allow-duplicate
+)
+
+// exitCode maps an error to the CLI exit code for its category
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, rubix.ErrTransferPending):
+		return exitTransferPending
+	case errors.Is(err, rubix.ErrTransferFailed):
+		return exitTransferFailed
+	case errors.Is(err, rubix.ErrInsufficientBalance):
+		return exitInsufficientBalance
+	case errors.Is(err, rubix.ErrInvalidReceiver), errors.Is(err, rubix.ErrRejected):
+		return exitInvalidRequest
+	case errors.Is(err, rubix.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, rubix.ErrUnauthorized):
+		return exitUnauthorized
+	case errors.Is(err, rubix.ErrSignatureRejected), errors.Is(err, rubix.ErrSignatureMismatch):
+		return exitSignatureRejected
+	case errors.Is(err, rubix.ErrNetwork), errors.Is(err, rubix.ErrTimeout), errors.Is(err, rubix.ErrNodeUnavailable):
+		return exitNodeUnreachable
+	case errors.Is(err, rubix.ErrMalformedResponse):
+		return exitMalformedResponse
+	case errors.Is(err, audit.ErrTampered):
+		return exitAuditTampered
+	}
+	return exitFailure
 }
 
 func main() {
@@ -81,12 +194,30 @@ func main() {
 		runBalance()
 	case "list-dids":
 		runListDIDs()
+	case "history":
+		runHistory()
+	case "receipts":
+		runReceipts()
 	case "export-dids":
 		runExportDIDs()
 	case "generate-key":
 		runGenerateKey()
 	case "break-nlss":
 		runBreakNLSS()
+	case "generate-nlss":
+		runGenerateNLSS()
+	case "scan":
+		runScan()
+	case "sign-message":
+		runSignMessage()
+	case "verify-message":
+		runVerifyMessage()
+	case "auth-server":
+		runAuthServer()
+	case "simulate-node":
+		runSimulateNode()
+	case "audit":
+		runAudit()
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -97,97 +228,50 @@ func main() {
 }
 
 func runTransfer() {
+	// Offline workflow subcommands
+	if len(os.Args) > 2 {
+		switch os.Args[2] {
+		case "prepare":
+			runTransferPrepare()
+			return
+		case "sign":
+			runTransferSign()
+			return
+		case "submit":
+			runTransferSubmit()
+			return
+		case "status":
+			runTransferStatus()
+			return
+		case "recover":
+			runTransferRecover()
+			return
+		}
+	}
+
 	transferCmd := flag.NewFlagSet("transfer", flag.ExitOnError)
 
 	// Standard mode flags
 	receiver := transferCmd.String("receiver", "", "Receiver DID (required)")
 	amount := transferCmd.Float64("amount", 0, "Amount to transfer (required)")
 	comment := transferCmd.String("comment", "", "Transfer comment (optional)")
-	rubixNode := transferCmd.String("rubix-node", "", "Rubix node URL (default: from env or localhost:20006)")
-	senderDID := transferCmd.String("sender-did", "", "Sender DID (default: from env)")
+	senderFlags := addTransferSenderFlags(transferCmd)
 
-	// File mode flags
-	fromFile := transferCmd.String("from-file", "", "Read sender info from accounts file")
-	senderIndex := transferCmd.Int("sender-index", -1, "Index of sender in accounts file (0-based)")
+	// Signing flags
+	inMemory := transferCmd.Bool("in-memory", false, "Rebuild the private share in memory from did.png and pubShare.png instead of reading pvtShare.png")
+	outcomeFlags := addTransferOutcomeFlags(transferCmd)
+	guardFlags := addDuplicateGuardFlags(transferCmd)
 
 	transferCmd.Parse(os.Args[2:])
 
-	// Validate required flags
-	if *receiver == "" {
-		fmt.Println("Error: --receiver is required")
-		transferCmd.Usage()
-		os.Exit(1)
-	}
-
-	if *amount <= 0 {
-		fmt.Println("Error: --amount must be greater than 0")
-		transferCmd.Usage()
-		os.Exit(1)
-	}
-
-	var finalSenderDID string
-	var senderBalance float64
-
-	// Check if using file mode
-	if *fromFile != "" {
-		if *senderIndex < 0 {
-			fmt.Println("Error: --sender-index is required when using --from-file")
-			transferCmd.Usage()
-			os.Exit(1)
-		}
-
-		// Load accounts from file
-		accountsFile, err := storage.LoadAccountsFromFile(*fromFile)
-		if err != nil {
-			fmt.Printf("Error loading accounts file: %v\n", err)
-			os.Exit(1)
-		}
-
-		// Get sender account by index
-		sender := accountsFile.GetAccountByIndex(*senderIndex)
-		if sender == nil {
-			fmt.Printf("Error: Invalid sender index %d. File has %d accounts.\n", *senderIndex, len(accountsFile.Accounts))
-			os.Exit(1)
-		}
-
-		finalSenderDID = sender.DID
-		senderBalance = sender.Balance
-
-		fmt.Printf("Using sender from file: %s (Balance: %.2f RBT)\n", finalSenderDID, senderBalance)
-
-		// Check if sender has enough balance
-		if senderBalance < *amount {
-			fmt.Printf("Error: Insufficient balance. Sender has %.2f RBT, trying to send %.2f RBT\n", senderBalance, *amount)
-			os.Exit(1)
-		}
-
-		// Use Rubix node URL from file if not overridden
-		if *rubixNode == "" {
-			*rubixNode = accountsFile.RubixNodeURL
-		}
-	} else {
-		// Standard mode - use flags or env
-		finalSenderDID = *senderDID
-	}
+	validateTransferFlagsOrExit(transferCmd, *receiver, *amount)
+	sender := resolveTransferSenderOrExit(transferCmd, senderFlags, *amount)
+	cfg := sender.Config
+	key := guardFlags.checkOrExit(cfg, *receiver, *amount, *comment)
+	sender.resolveSignerKindOrExit()
 
-	// Load configuration
-	cfg, err := config.LoadConfigWithOverrides(*rubixNode, finalSenderDID)
-	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		fmt.Printf("Configuration error: %v\n", err)
-		fmt.Println("\nPlease set the following environment variables:")
-		fmt.Println("  RUBIX_NODE_URL  - Rubix node URL")
-		fmt.Println("  SENDER_DID      - Your DID")
-		fmt.Println("\nOr use command-line flags:")
-		fmt.Println("  --from-file accounts.json --sender-index 0")
-		transferCmd.Usage()
-		os.Exit(1)
-	}
+	// Build the signer before initiating, so missing key material fails early
+	signer, signerDesc := sender.newSignerOrExit(*inMemory)
 
 	// Print configuration
 	fmt.Println("\nTransfer Configuration:")
@@ -195,325 +279,2689 @@ func runTransfer() {
 	cfg.PrintConfig()
 	fmt.Printf("  Receiver: %s\n", *receiver)
 	fmt.Printf("  Amount: %.2f RBT\n", *amount)
-	if *fromFile != "" {
-		fmt.Printf("  Sender Balance: %.2f RBT\n", senderBalance)
+	if sender.FromFile {
+		fmt.Printf("  Sender Balance: %.2f RBT\n", sender.Balance)
 	}
 	fmt.Printf("  Comment: %s\n", *comment)
+	fmt.Printf("  Signing: %s\n", signerDesc)
 	fmt.Println()
 
 	// Perform transfer
 	params := rubix.TransferParams{
-		RubixNodeURL:  cfg.RubixNodeURL,
-		SenderDID:     cfg.SenderDID,
-		ReceiverDID:   *receiver,
-		Amount:        *amount,
-		Comment:       *comment,
-		NLSSOutputDir: cfg.NLSSOutputDir,
+		RubixNodeURL:   cfg.RubixNodeURL,
+		SenderDID:      cfg.SenderDID,
+		ReceiverDID:    *receiver,
+		Amount:         *amount,
+		Comment:        *comment,
+		NLSSOutputDir:  cfg.NLSSOutputDir,
+		Signer:         signer,
+		Client:         newRubixClientOrExit(cfg, cfg.RubixNodeURL),
+		Wait:           outcomeFlags.waitTimeout(),
+		IdempotencyKey: key,
+	}
+	if ledger := openLedgerOrExit(cfg); ledger != nil {
+		defer ledger.Close()
+		params.OnUpdate = func(r *rubix.TransferResult) error { return recordTransfer(ledger, r) }
+	}
+	if sender.SignerKind != rubix.SignerECDSA {
+		params.DIDImagePath, params.PubSharePath = localVerifyPaths(cfg)
 	}
 
-	if err := rubix.TransferTokens(params); err != nil {
-		fmt.Printf("\nError: %v\n", err)
-		os.Exit(1)
+	result, err := rubix.TransferTokens(params)
+	if result == nil {
+		// Not initiated
+		result = &rubix.TransferResult{SenderDID: params.SenderDID, ReceiverDID: params.ReceiverDID, Amount: params.Amount,
+			Comment: params.Comment, State: rubix.TransferFailed, UpdatedAt: time.Now()}
 	}
+	outcomeFlags.finishOrExit(result, err)
 }
 
-func runBalance() {
-	balanceCmd := flag.NewFlagSet("balance", flag.ExitOnError)
+// runTransferPrepare initiates a transfer and saves the request to sign offline
+func runTransferPrepare() {
+	prepareCmd := flag.NewFlagSet("transfer prepare", flag.ExitOnError)
 
-	did := balanceCmd.String("did", "", "DID to query (default: from env SENDER_DID)")
-	rubixNode := balanceCmd.String("rubix-node", "", "Rubix node URL (default: from env or localhost:20006)")
+	receiver := prepareCmd.String("receiver", "", "Receiver DID (required)")
+	amount := prepareCmd.Float64("amount", 0, "Amount to transfer (required)")
+	comment := prepareCmd.String("comment", "", "Transfer comment (optional)")
+	output := prepareCmd.String("output", "transfer.json", "Unsigned transfer bundle to write")
+	senderFlags := addTransferSenderFlags(prepareCmd)
+	guardFlags := addDuplicateGuardFlags(prepareCmd)
 
-	balanceCmd.Parse(os.Args[2:])
+	prepareCmd.Parse(os.Args[3:])
 
-	// Load configuration
-	cfg, err := config.LoadConfigWithOverrides(*rubixNode, *did)
-	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
-		os.Exit(1)
-	}
+	validateTransferFlagsOrExit(prepareCmd, *receiver, *amount)
+	sender := resolveTransferSenderOrExit(prepareCmd, senderFlags, *amount)
+	cfg := sender.Config
+	key := guardFlags.checkOrExit(cfg, *receiver, *amount, *comment)
+	sender.resolveSignerKindOrExit()
 
-	// Use SENDER_DID if --did not provided
-	queryDID := *did
-	if queryDID == "" {
-		queryDID = cfg.SenderDID
+	params := rubix.TransferParams{
+		RubixNodeURL:   cfg.RubixNodeURL,
+		SenderDID:      cfg.SenderDID,
+		ReceiverDID:    *receiver,
+		Amount:         *amount,
+		Comment:        *comment,
+		Client:         newRubixClientOrExit(cfg, cfg.RubixNodeURL),
+		IdempotencyKey: key,
 	}
 
-	if queryDID == "" {
-		fmt.Println("Error: --did is required or set SENDER_DID environment variable")
-		balanceCmd.Usage()
+	ledger := openLedgerOrExit(cfg)
+	if ledger != nil {
+		defer ledger.Close()
+	}
+	result, err := rubix.NewTransferResult(params)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := recordTransfer(ledger, result); err != nil {
+		fmt.Println("Error: transfer not initiated, since the ledger could not record it")
 		os.Exit(1)
 	}
 
-	fmt.Printf("Querying balance for DID: %s\n", queryDID)
-	fmt.Printf("Rubix Node: %s\n\n", cfg.RubixNodeURL)
-
-	// Get balance
-	balance, err := rubix.GetAccountBalance(cfg.RubixNodeURL, queryDID)
+	pending, err := rubix.PrepareTransfer(params)
 	if err != nil {
+		result.State, result.Error = rubix.TransferFailed, err.Error()
+		recordTransfer(ledger, result)
+		fmt.Printf("\nError: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	bundle := rubix.NewTransferBundle(params, pending)
+	bundle.NodeName = cfg.NLSSNodeName
+	bundle.Signer = sender.SignerKind
+	bundle.TransferID, bundle.IdempotencyKey = result.ID, result.IdempotencyKey
+	bundle.PreparedAt = result.CreatedAt
+	recordTransfer(ledger, bundle.Result())
+	if err := rubix.SaveTransferBundle(*output, bundle); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Balance: %.2f RBT\n", balance)
+	fmt.Printf("\n✓ Unsigned transfer saved to: %s\n", *output)
+	fmt.Println("\nNext steps:")
+	fmt.Printf("  1. Copy %s to the offline machine holding the private share\n", *output)
+	fmt.Printf("  2. ./break-nlss transfer sign --bundle %s --output transfer.signed.json\n", *output)
+	fmt.Println("  3. Copy transfer.signed.json back and run:")
+	fmt.Println("     ./break-nlss transfer submit --bundle transfer.signed.json")
 }
 
-func runListDIDs() {
-	listCmd := flag.NewFlagSet("list-dids", flag.ExitOnError)
+// runTransferSign signs a prepared transfer bundle; it needs no network access
+func runTransferSign() {
+	signCmd := flag.NewFlagSet("transfer sign", flag.ExitOnError)
 
-	rubixNode := listCmd.String("rubix-node", "", "Rubix node URL (default: from env or localhost:20006)")
+	bundlePath := signCmd.String("bundle", "", "Unsigned transfer bundle from 'transfer prepare' (required)")
+	output := signCmd.String("output", "transfer.signed.json", "Signed transfer bundle to write")
+	inMemory := signCmd.Bool("in-memory", false, "Rebuild the private share in memory from did.png and pubShare.png instead of reading pvtShare.png")
+	signerFlag := signCmd.String("signer", "", "Signing method: nlss, ecdsa or nlss+ecdsa (default: from the bundle)")
+	keyFile := signCmd.String("key-file", "", "EC private key PEM for ecdsa signing (default: pvtKey.pem in the DID folder; password from env PRIVATE_KEY_PASSWORD)")
 
-	listCmd.Parse(os.Args[2:])
+	signCmd.Parse(os.Args[3:])
 
-	// Load configuration
-	cfg, err := config.LoadConfigWithOverrides(*rubixNode, "")
+	if *bundlePath == "" {
+		fmt.Println("Error: --bundle is required")
+		signCmd.Usage()
+		os.Exit(1)
+	}
+
+	bundle, err := rubix.LoadTransferBundle(*bundlePath)
 	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if bundle.Signed() {
+		fmt.Printf("Error: %s is already signed\n", *bundlePath)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Fetching all DIDs from: %s\n\n", cfg.RubixNodeURL)
+	cfg, err := config.LoadConfigWithOverrides("", bundle.SenderDID)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if bundle.NodeName != "" {
+		cfg = cfg.ForNode(bundle.NodeName)
+	}
 
-	// Get all DIDs
-	client := rubix.NewClient(cfg.RubixNodeURL)
-	response, err := client.GetAllDID()
+	kind := *signerFlag
+	if kind == "" {
+		kind = bundle.Signer
+	}
+	signer, signerDesc, err := newTransferSigner(cfg, kind, *keyFile, *inMemory)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	defer rubix.CloseSigner(signer)
+
+	fmt.Println("Signing Transfer:")
+	fmt.Println("=================")
+	fmt.Printf("  Request ID: %s\n", bundle.RequestID)
+	fmt.Printf("  Sender: %s\n", bundle.SenderDID)
+	fmt.Printf("  Receiver: %s\n", bundle.ReceiverDID)
+	fmt.Printf("  Amount: %.2f RBT\n", bundle.Amount)
+	fmt.Printf("  Comment: %s\n", bundle.Comment)
+	fmt.Printf("  Signing: %s\n", signerDesc)
+
+	sig, err := rubix.SignTransfer(bundle.Hash, signer)
+	if err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		os.Exit(1)
+	}
+	rubix.CloseSigner(signer)
 
-	fmt.Printf("Status: %v\n", response.Status)
-	fmt.Printf("Message: %s\n", response.Message)
-	fmt.Printf("Total DIDs: %d\n\n", len(response.AccountInfo))
+	if len(sig.Pixels) > 0 {
+		verifySignatureOrExit(cfg, bundle.Hash, sig, rubix.ShareSource(signer))
+	}
 
-	fmt.Println("Account Information:")
-	fmt.Println("====================")
-	for i, account := range response.AccountInfo {
-		fmt.Printf("\n[%d] DID: %s\n", i+1, account.DID)
-		fmt.Printf("    Type: %d\n", account.DIDType)
-		fmt.Printf("    RBT Amount: %.2f\n", account.RBTAmount)
-		fmt.Printf("    Pledged: %.2f | Locked: %.2f | Pinned: %.2f\n",
-			account.PledgedRBT, account.LockedRBT, account.PinnedRBT)
+	bundle.Attach(sig)
+	if err := rubix.SaveTransferBundle(*output, bundle); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
+
+	fmt.Printf("\n✓ Signed transfer saved to: %s\n", *output)
+	fmt.Printf("  Submit it from a networked machine with: ./break-nlss transfer submit --bundle %s\n", *output)
 }
 
-func runExportDIDs() {
-	exportCmd := flag.NewFlagSet("export-dids", flag.ExitOnError)
+// runTransferSubmit submits a signed transfer bundle to the node
+func runTransferSubmit() {
+	submitCmd := flag.NewFlagSet("transfer submit", flag.ExitOnError)
 
-	output := exportCmd.String("output", "accounts.json", "Output file path")
-	rubixNode := exportCmd.String("rubix-node", "", "Rubix node URL (default: from env or localhost:20006)")
-	minBalance := exportCmd.Float64("min-balance", 0.0, "Minimum balance to include (default: 0, only non-zero balances)")
+	bundlePath := submitCmd.String("bundle", "", "Signed transfer bundle from 'transfer sign' (required)")
+	rubixNode := submitCmd.String("rubix-node", "", "Rubix node URL (default: from the bundle)")
+	outcomeFlags := addTransferOutcomeFlags(submitCmd)
 
-	exportCmd.Parse(os.Args[2:])
+	submitCmd.Parse(os.Args[3:])
 
-	// Load configuration
-	cfg, err := config.LoadConfigWithOverrides(*rubixNode, "")
-	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
+	if *bundlePath == "" {
+		fmt.Println("Error: --bundle is required")
+		submitCmd.Usage()
 		os.Exit(1)
 	}
 
-	fmt.Printf("Fetching DIDs from: %s\n", cfg.RubixNodeURL)
-	fmt.Printf("Minimum balance filter: %.2f RBT\n\n", *minBalance)
-
-	// Get all DIDs
-	client := rubix.NewClient(cfg.RubixNodeURL)
-	response, err := client.GetAllDID()
+	bundle, err := rubix.LoadTransferBundle(*bundlePath)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Printf("Total DIDs on node: %d\n", len(response.AccountInfo))
-
-	// Filter DIDs with balance > minBalance
-	var accounts []storage.DIDAccount
-	for _, account := range response.AccountInfo {
-		if account.RBTAmount > *minBalance {
-			accounts = append(accounts, storage.DIDAccount{
-				DID:        account.DID,
-				Balance:    account.RBTAmount,
-				DIDType:    account.DIDType,
-				PledgedRBT: account.PledgedRBT,
-				LockedRBT:  account.LockedRBT,
-				PinnedRBT:  account.PinnedRBT,
-				UpdatedAt:  time.Now(),
-			})
-		}
+	if !bundle.Signed() {
+		fmt.Printf("Error: %s is not signed yet; run 'transfer sign' first\n", *bundlePath)
+		os.Exit(1)
 	}
 
-	fmt.Printf("DIDs with balance > %.2f: %d\n\n", *minBalance, len(accounts))
-
-	if len(accounts) == 0 {
-		fmt.Println("No DIDs found with the specified balance criteria.")
-		os.Exit(0)
+	nodeURL := bundle.RubixNodeURL
+	if *rubixNode != "" {
+		nodeURL = *rubixNode
 	}
+	nodeURL = issuingNodeOrExit(bundle.RequestID, bundle.RubixNodeURL, nodeURL)
 
-	// Save to file
-	err = storage.SaveAccountsToFile(*output, accounts, cfg.RubixNodeURL)
+	cfg, err := config.LoadConfigWithOverrides("", bundle.SenderDID)
 	if err != nil {
-		fmt.Printf("Error saving to file: %v\n", err)
+		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Printf("✓ Successfully exported %d DIDs to: %s\n\n", len(accounts), *output)
-
-	// Print summary
-	fmt.Println("Exported Accounts:")
-	fmt.Println("==================")
-	for i, account := range accounts {
-		fmt.Printf("[%d] DID: %s\n", i, account.DID)
-		fmt.Printf("    Balance: %.2f RBT\n", account.Balance)
-		fmt.Printf("    Pledged: %.2f | Locked: %.2f | Pinned: %.2f\n\n",
-			account.PledgedRBT, account.LockedRBT, account.PinnedRBT)
+	if bundle.NodeName != "" {
+		cfg = cfg.ForNode(bundle.NodeName)
 	}
 
-	fmt.Println("Usage:")
-	fmt.Printf("  ./break-nlss transfer --from-file %s --sender-index 0 --receiver <DID> --amount <AMOUNT>\n", *output)
-}
-
-func runGenerateKey() {
-	genCmd := flag.NewFlagSet("generate-key", flag.ExitOnError)
-
-	outputDir := genCmd.String("output", "./preset", "Output directory for key files")
-
-	genCmd.Parse(os.Args[2:])
+	// Verify again where the DID images are available, in case the bundle was signed elsewhere
+	if len(bundle.Signature.Pixels) > 0 {
+		verifySignatureOrExit(cfg, bundle.Hash, bundle.Signature, "the private share that signed "+*bundlePath)
+	}
 
-	fmt.Printf("Generating new EC key pair (P-256)...\n")
-	fmt.Printf("Output directory: %s\n\n", *outputDir)
+	fmt.Println("Submitting Transfer:")
+	fmt.Println("====================")
+	fmt.Printf("  Rubix Node URL: %s\n", nodeURL)
+	fmt.Printf("  Request ID: %s\n", bundle.RequestID)
+	fmt.Printf("  Sender: %s\n", bundle.SenderDID)
+	fmt.Printf("  Receiver: %s\n", bundle.ReceiverDID)
+	fmt.Printf("  Amount: %.2f RBT\n", bundle.Amount)
+
+	ledger := openLedgerOrExit(cfg)
+	if ledger != nil {
+		defer ledger.Close()
+	}
 
-	// Ensure output directory exists
-	if err := os.MkdirAll(*outputDir, 0755); err != nil {
-		fmt.Printf("Error creating output directory: %v\n", err)
+	client := newRubixClientOrExit(cfg, nodeURL)
+	result := bundle.Result()
+	result.NodeURL = nodeURL
+	if err := recordTransfer(ledger, result); err != nil {
+		fmt.Println("Error: signature not submitted, since the ledger could not record it")
 		os.Exit(1)
 	}
-
-	privateKeyPath := fmt.Sprintf("%s/privatekey.pem", *outputDir)
-	publicKeyPath := fmt.Sprintf("%s/publickey.pem", *outputDir)
-
-	// Generate keys
-	_, err := rubix.GenerateAndSaveKeys(privateKeyPath, publicKeyPath)
+	signResp, err := rubix.SubmitTransfer(client, bundle.RequestID, bundle.Signature)
+	result.RecordSubmission(signResp, err)
+	recordTransfer(ledger, result)
 	if err != nil {
-		fmt.Printf("Error generating keys: %v\n", err)
-		os.Exit(1)
+		outcomeFlags.finishOrExit(result, err)
 	}
 
-	fmt.Printf("✓ Key pair generated successfully!\n")
-	fmt.Printf("  Private key: %s\n", privateKeyPath)
-	fmt.Printf("  Public key: %s\n", publicKeyPath)
-	fmt.Println("\nIMPORTANT: Keep your private key secure and never share it!")
+	fmt.Printf("\n✓ Transaction completed successfully!\n")
+	fmt.Printf("  Message: %s\n", signResp.Message)
+
+	err = outcomeFlags.waitOrSkip(client, result)
+	if *outcomeFlags.wait {
+		recordTransfer(ledger, result)
+	}
+	outcomeFlags.finishOrExit(result, err)
 }
 
-func runBreakNLSS() {
-	breakCmd := flag.NewFlagSet("break-nlss", flag.ExitOnError)
+// runTransferStatus looks up a submitted transfer, optionally waiting until it is final
+func runTransferStatus() {
+	statusCmd := flag.NewFlagSet("transfer status", flag.ExitOnError)
 
-	didInput := breakCmd.String("did", "", "DID string or path to file containing DIDs (required)")
+	requestID := statusCmd.String("request-id", "", "Request ID from the initiate step (needs the node that initiated the transfer)")
+	txnID := statusCmd.String("txn-id", "", "Transaction ID from the node's submit response")
+	resultPath := statusCmd.String("result", "", "Result file from --result-file to read the IDs and node from")
+	rubixNode := statusCmd.String("rubix-node", "", "Rubix node URL (default: from the result file, else from env or localhost:20006)")
+	outcomeFlags := addTransferOutcomeFlags(statusCmd)
 
-	breakCmd.Parse(os.Args[2:])
+	statusCmd.Parse(os.Args[3:])
 
-	// Validate required flags
-	if *didInput == "" {
-		fmt.Println("Error: --did is required")
-		fmt.Println("\nUsage:")
-		fmt.Println("  Single DID:")
-		fmt.Println("    break-nlss break-nlss --did bafybmifeh7csi6wuuwqd3c7cxcwk5k3e3nd2f73x2hxa2teojhkd6ztdse")
-		fmt.Println("  Multiple DIDs from file:")
-		fmt.Println("    break-nlss break-nlss --did dids.txt")
-		breakCmd.Usage()
+	result := &rubix.TransferResult{RequestID: *requestID, TransactionID: *txnID}
+	if *resultPath != "" {
+		loaded, err := rubix.LoadTransferResult(*resultPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		result = loaded
+		if *requestID != "" {
+			result.RequestID = *requestID
+		}
+		if *txnID != "" {
+			result.TransactionID = *txnID
+		}
+	}
+	if result.RequestID == "" && result.TransactionID == "" {
+		fmt.Println("Error: --request-id, --txn-id or --result is required")
+		statusCmd.Usage()
 		os.Exit(1)
 	}
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfigWithOverrides(*rubixNode, "")
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Determine if input is a file or a single DID
-	var dids []string
-	if _, err := os.Stat(*didInput); err == nil {
-		// It's a file, read DIDs from it
-		fmt.Printf("Reading DIDs from file: %s\n", *didInput)
-		dids, err = readDIDsFromFile(*didInput)
-		if err != nil {
-			fmt.Printf("Error reading DIDs from file: %v\n", err)
-			os.Exit(1)
+	// The ledger knows the node and IDs of transfers made from this machine
+	ledger := openLedgerOrExit(cfg)
+	if ledger != nil {
+		defer ledger.Close()
+		if receipt, ok := findReceiptOrExit(cfg, result.RequestID, result.TransactionID); ok && *resultPath == "" {
+			result = transferResultFromEntry(receipt)
 		}
-		fmt.Printf("Found %d DIDs to process\n\n", len(dids))
-	} else {
-		// It's a single DID
-		dids = []string{*didInput}
+	}
+	if *rubixNode == "" && result.NodeURL != "" {
+		cfg.RubixNodeURL = result.NodeURL
+	}
+	client := newRubixClientOrExit(cfg, cfg.RubixNodeURL)
+	if result.TransactionID == "" && len(client.Endpoints) > 1 {
+		fmt.Println("Error: request IDs are only known to the node that initiated the transfer; pass that node with --rubix-node")
+		os.Exit(1)
 	}
 
-	// Process each DID
-	successCount := 0
+	fmt.Printf("Transfer status from: %s\n", cfg.RubixNodeURL)
+	if *outcomeFlags.wait {
+		err = outcomeFlags.waitOrSkip(client, result)
+	} else {
+		var st *rubix.TransferStatus
+		st, err = client.TransferStatus(context.Background(), result.RequestID, result.TransactionID)
+		if err == nil {
+			result.Update(st)
+			if st.State == rubix.TransferFailed {
+				err = fmt.Errorf("%w: %s", rubix.ErrTransferFailed, st.Message)
+			}
+		}
+	}
+
+	if result.State != "" {
+		fmt.Printf("  Request ID: %s\n", result.RequestID)
+		fmt.Printf("  Transaction ID: %s\n", result.TransactionID)
+		fmt.Printf("  State: %s\n", result.State)
+		if result.Message != "" {
+			fmt.Printf("  Message: %s\n", result.Message)
+		}
+	}
+	if err == nil && result.State == rubix.TransferPending {
+		// Not final yet; scripts polling without --wait can tell from the exit code
+		err = fmt.Errorf("%w: run again later or use --wait", rubix.ErrTransferPending)
+	}
+	if result.SenderDID != "" {
+		recordTransfer(ledger, result)
+	}
+	outcomeFlags.finishOrExit(result, err)
+}
+
+// runTransferRecover lists transfers interrupted before their outcome was
+// known, and resumes or abandons one of them
+func runTransferRecover() {
+	recoverCmd := flag.NewFlagSet("transfer recover", flag.ExitOnError)
+
+	id := recoverCmd.String("id", "", "Transfer ID or request ID of the transfer to recover (default: list unfinished transfers)")
+	resume := recoverCmd.Bool("resume", false, "Sign and submit the transfer, unless the node already processed it")
+	abandon := recoverCmd.Bool("abandon", false, "Give up the transfer, unless the node already processed it")
+	force := recoverCmd.Bool("force", false, "Resume or abandon even when the node cannot tell whether the request was processed")
+	rubixNode := recoverCmd.String("rubix-node", "", "Rubix node URL (default: the node that initiated the transfer)")
+	signerFlag := recoverCmd.String("signer", "", "Signing method: nlss, ecdsa or nlss+ecdsa (default: from the sender's DID type)")
+	keyFile := recoverCmd.String("key-file", "", "EC private key PEM for ecdsa or nlss+ecdsa (default: pvtKey.pem in the DID folder)")
+	inMemory := recoverCmd.Bool("in-memory", false, "Rebuild the private share in memory from did.png and pubShare.png instead of reading pvtShare.png")
+	nodesFlag := recoverCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH to locate the sender DID on (default: from env NLSS_NODES)")
+	outcomeFlags := addTransferOutcomeFlags(recoverCmd)
+
+	recoverCmd.Parse(os.Args[3:])
+
+	cfg, err := config.LoadConfigWithOverrides(*rubixNode, "")
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.LedgerPath == "" {
+		fmt.Println("Error: the ledger is disabled (LEDGER_PATH=off); there is nothing to recover from")
+		os.Exit(1)
+	}
+
+	if *id == "" {
+		var unfinished []storage.LedgerEntry
+		for _, r := range loadReceiptsOrExit(cfg) {
+			if r.Unfinished() {
+				unfinished = append(unfinished, r)
+			}
+		}
+		if len(unfinished) == 0 {
+			fmt.Printf("✓ No unfinished transfers in %s\n", cfg.LedgerPath)
+			return
+		}
+		fmt.Printf("%d unfinished transfers in %s:\n", len(unfinished), cfg.LedgerPath)
+		printUnfinishedTransfers(unfinished)
+		return
+	}
+	if *resume == *abandon {
+		fmt.Println("Error: pass either --resume or --abandon")
+		recoverCmd.Usage()
+		os.Exit(1)
+	}
+
+	receipt, ok := findReceiptOrExit(cfg, *id, "")
+	if !ok {
+		fmt.Printf("Error: no transfer %s in %s\n", *id, cfg.LedgerPath)
+		os.Exit(exitNotFound)
+	}
+	if !receipt.Unfinished() {
+		fmt.Printf("Transfer %s is already %s; nothing to recover\n", receipt.TransferID(), receipt.State)
+		os.Exit(1)
+	}
+	result := transferResultFromEntry(receipt)
+	ledger := openLedgerOrExit(cfg)
+	defer ledger.Close()
+
+	nodeURL := result.NodeURL
+	if *rubixNode != "" || nodeURL == "" {
+		nodeURL = cfg.RubixNodeURL
+	}
+	if result.RequestID != "" {
+		nodeURL = issuingNodeOrExit(result.RequestID, result.NodeURL, nodeURL)
+	}
+	client := newRubixClientOrExit(cfg, nodeURL)
+
+	fmt.Printf("Recovering transfer %s (%s)\n", receipt.TransferID(), result.State)
+	fmt.Printf("  Request ID: %s\n", result.RequestID)
+	fmt.Printf("  Receiver: %s\n", result.ReceiverDID)
+	fmt.Printf("  Amount: %.2f RBT\n", result.Amount)
+	fmt.Printf("  Node: %s\n\n", nodeURL)
+
+	if *abandon {
+		abandonTransferOrExit(client, ledger, result, *force)
+		return
+	}
+
+	if !result.Resumable() {
+		fmt.Println("Error: the transfer was interrupted before the node issued a request, so there is nothing to sign")
+		fmt.Printf("Abandon it with --abandon and start it again (--allow-duplicate)\n")
+		os.Exit(1)
+	}
+
+	params := rubix.TransferParams{
+		SenderDID:   result.SenderDID,
+		ReceiverDID: result.ReceiverDID,
+		Amount:      result.Amount,
+		Comment:     result.Comment,
+		Client:      client,
+		Wait:        outcomeFlags.waitTimeout(),
+		OnUpdate:    func(r *rubix.TransferResult) error { return recordTransfer(ledger, r) },
+	}
+	if result.Signature == nil {
+		sender := &transferSender{Config: loadDIDConfigOrExit(result.SenderDID, *nodesFlag), SignerKind: *signerFlag, KeyFile: *keyFile, DIDType: -1}
+		sender.Config.RubixNodeURL = nodeURL
+		sender.resolveSignerKindOrExit()
+		signer, signerDesc := sender.newSignerOrExit(*inMemory)
+		fmt.Printf("Signing: %s\n", signerDesc)
+		params.Signer = signer
+		if sender.SignerKind != rubix.SignerECDSA {
+			params.DIDImagePath, params.PubSharePath = localVerifyPaths(sender.Config)
+		}
+	}
+
+	result, err = rubix.ResumeTransfer(params, result, *force)
+	if errors.Is(err, rubix.ErrStatusUnknown) {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Check the sender's transactions (break-nlss history) and pass --force only if it did not execute")
+		os.Exit(1)
+	}
+	outcomeFlags.finishOrExit(result, err)
+	fmt.Printf("\n✓ Transfer %s resumed: %s\n", receipt.TransferID(), result.State)
+}
+
+// issuingNodeOrExit narrows a comma-separated nodeURL to issuedBy, the node
+// that initiated requestID. Request IDs are node-local, so the signature must
+// not fail over to another node.
+func issuingNodeOrExit(requestID, issuedBy, nodeURL string) string {
+	urls := rubix.SplitNodeURLs(nodeURL)
+	if len(urls) <= 1 {
+		return nodeURL
+	}
+	if !slices.Contains(urls, issuedBy) {
+		fmt.Printf("Error: request %s was initiated on %q, which is not in %s\n", requestID, issuedBy, nodeURL)
+		fmt.Println("Pass that node alone as --rubix-node")
+		os.Exit(1)
+	}
+	return issuedBy
+}
+
+// abandonTransferOrExit gives up an unfinished transfer after making sure the
+// node has not processed its signature; with force, also when the node cannot tell
+func abandonTransferOrExit(client *rubix.Client, ledger *storage.Ledger, result *rubix.TransferResult, force bool) {
+	if result.State == rubix.TransferPending {
+		fmt.Println("Error: the signature was already submitted and may still execute")
+		fmt.Println("Look up its outcome with: break-nlss transfer status --request-id " + result.RequestID)
+		os.Exit(1)
+	}
+	checked := false
+	if result.RequestID != "" {
+		done, err := client.RefreshResult(context.Background(), result)
+		switch {
+		case errors.Is(err, rubix.ErrStatusUnknown) && force:
+			fmt.Printf("⚠ %v; abandoning anyway\n", err)
+		case errors.Is(err, rubix.ErrStatusUnknown):
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Check the sender's transactions (break-nlss history) and pass --force only if it did not execute")
+			os.Exit(1)
+		case err != nil:
+			fmt.Printf("Error: could not check request %s with the node: %v\n", result.RequestID, err)
+			os.Exit(exitCode(err))
+		default:
+			checked = true
+		}
+		if done {
+			recordTransfer(ledger, result)
+			fmt.Printf("The node already processed request %s: %s\n", result.RequestID, result.State)
+			os.Exit(1)
+		}
+	}
+
+	result.Abandon("abandoned with transfer recover")
+	recordTransfer(ledger, result)
+	fmt.Printf("✓ Transfer %s abandoned\n", storage.LedgerEntry{ID: result.ID, RequestID: result.RequestID}.TransferID())
+	if checked {
+		fmt.Println("  The node never received its signature, so the request cannot execute")
+	}
+}
+
+// transferOutcomeFlags are the finality and result flags shared by commands that submit or track transfers
+type transferOutcomeFlags struct {
+	wait       *bool
+	timeout    *time.Duration
+	resultFile *string
+}
+
+func addTransferOutcomeFlags(fs *flag.FlagSet) *transferOutcomeFlags {
+	return &transferOutcomeFlags{
+		wait:       fs.Bool("wait", false, "Poll until the transfer is confirmed or failed"),
+		timeout:    fs.Duration("wait-timeout", 2*time.Minute, "How long --wait polls before giving up"),
+		resultFile: fs.String("result-file", "", "Write the final outcome as JSON to this file"),
+	}
+}
+
+// waitTimeout returns how long to wait for finality, or 0 without --wait
+func (f *transferOutcomeFlags) waitTimeout() time.Duration {
+	if !*f.wait {
+		return 0
+	}
+	return *f.timeout
+}
+
+// waitOrSkip waits for the result's transfer to be final if --wait is set
+func (f *transferOutcomeFlags) waitOrSkip(client *rubix.Client, result *rubix.TransferResult) error {
+	if !*f.wait {
+		return nil
+	}
+	fmt.Println("\nWaiting for the transfer to be finalized...")
+	if err := client.WaitForResult(result, *f.timeout, rubix.DefaultPollInterval); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Transfer confirmed (transaction %s)\n", result.TransactionID)
+	return nil
+}
+
+// finishOrExit writes the result file if requested and exits with the error's code
+func (f *transferOutcomeFlags) finishOrExit(result *rubix.TransferResult, err error) {
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+	if *f.resultFile != "" {
+		if err := rubix.SaveTransferResult(*f.resultFile, result); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Result (%s) saved to: %s\n", result.State, *f.resultFile)
+	}
+	if err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// localVerifyPaths returns the sender's DID image and public share for local
+// signature verification, or empty paths with a warning when they are not available
+func localVerifyPaths(cfg *config.Config) (didImagePath, pubSharePath string) {
+	didImagePath, pubSharePath, err := cfg.GetNLSSImagePaths(cfg.SenderDID)
+	if err == nil {
+		_, err = os.Stat(didImagePath)
+	}
+	if err == nil {
+		_, err = os.Stat(pubSharePath)
+	}
+	if err != nil {
+		fmt.Printf("⚠ Skipping local signature verification: %v\n", err)
+		return "", ""
+	}
+	return didImagePath, pubSharePath
+}
+
+// verifySignatureOrExit verifies an image signature locally and exits if it does not match
+func verifySignatureOrExit(cfg *config.Config, hash string, sig *rubix.SignatureData, source string) {
+	didImagePath, pubSharePath := localVerifyPaths(cfg)
+	if didImagePath == "" {
+		return
+	}
+	if err := rubix.VerifyPixels(didImagePath, pubSharePath, hash, sig, source); err != nil {
+		fmt.Printf("\n❌ Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Println("✓ Image signature verified locally")
+}
+
+// transferSenderFlags select and locate the sender of a transfer
+type transferSenderFlags struct {
+	rubixNode   *string
+	senderDID   *string
+	fromFile    *string
+	senderIndex *int
+	nodes       *string
+	signer      *string
+	keyFile     *string
+	didType     *int
+}
+
+// addTransferSenderFlags registers the sender and signing flags shared by transfer commands
+func addTransferSenderFlags(fs *flag.FlagSet) *transferSenderFlags {
+	return &transferSenderFlags{
+		rubixNode: fs.String("rubix-node", "", "Rubix node URL, host:port or https://...; comma-separated for failover (default: from env or localhost:20006)"),
+		senderDID: fs.String("sender-did", "", "Sender DID (default: from env)"),
+
+		// File mode flags
+		fromFile:    fs.String("from-file", "", "Read sender info from accounts file"),
+		senderIndex: fs.Int("sender-index", -1, "Index of sender in accounts file (0-based)"),
+
+		// Multi-node flags
+		nodes: fs.String("nodes", "", "Node names or globs under NLSS_BASE_PATH to locate the sender on (default: from env NLSS_NODES)"),
+
+		// Signing flags
+		signer:  fs.String("signer", "", "Signing method: nlss, ecdsa or nlss+ecdsa (default: from accounts file, else from the DID type)"),
+		keyFile: fs.String("key-file", "", "EC private key PEM for ecdsa signing (default: from accounts file, else pvtKey.pem in the DID folder; password from env PRIVATE_KEY_PASSWORD)"),
+		didType: fs.Int("did-type", -1, "Sender DID type: 0 basic, 1 standard, 2 wallet, 3 child, 4 lite (default: from accounts file, else queried from the node)"),
+	}
+}
+
+// transferSender is the resolved sender of a transfer
+type transferSender struct {
+	Config     *config.Config
+	Balance    float64
+	FromFile   bool
+	SignerKind string
+	KeyFile    string
+	DIDType    int // -1 when unknown
+}
+
+// validateTransferFlagsOrExit checks the receiver and amount flags
+func validateTransferFlagsOrExit(fs *flag.FlagSet, receiver string, amount float64) {
+	if receiver == "" {
+		fmt.Println("Error: --receiver is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if amount <= 0 {
+		fmt.Println("Error: --amount must be greater than 0")
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+// resolveTransferSenderOrExit loads the sender from the accounts file or flags and env,
+// and ties it to its node in multi-node mode
+func resolveTransferSenderOrExit(fs *flag.FlagSet, f *transferSenderFlags, amount float64) *transferSender {
+	var finalSenderDID string
+	var senderNode string
+	rubixNode := *f.rubixNode
+	sender := &transferSender{SignerKind: *f.signer, KeyFile: *f.keyFile, DIDType: *f.didType}
+
+	// Check if using file mode
+	if *f.fromFile != "" {
+		if *f.senderIndex < 0 {
+			fmt.Println("Error: --sender-index is required when using --from-file")
+			fs.Usage()
+			os.Exit(1)
+		}
+
+		// Load accounts from file
+		accountsFile, err := storage.LoadAccountsFromFile(*f.fromFile)
+		if err != nil {
+			fmt.Printf("Error loading accounts file: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get sender account by index
+		account := accountsFile.GetAccountByIndex(*f.senderIndex)
+		if account == nil {
+			fmt.Printf("Error: Invalid sender index %d. File has %d accounts.\n", *f.senderIndex, len(accountsFile.Accounts))
+			os.Exit(1)
+		}
+
+		finalSenderDID = account.DID
+		sender.Balance = account.Balance
+		sender.FromFile = true
+
+		fmt.Printf("Using sender from file: %s (Balance: %.2f RBT)\n", finalSenderDID, sender.Balance)
+
+		// Check if sender has enough balance
+		if sender.Balance < amount {
+			fmt.Printf("Error: Insufficient balance. Sender has %.2f RBT, trying to send %.2f RBT\n", sender.Balance, amount)
+			os.Exit(1)
+		}
+
+		// Per-DID signing settings apply unless overridden by flags
+		if sender.SignerKind == "" {
+			sender.SignerKind = account.Signer
+		}
+		if sender.KeyFile == "" {
+			sender.KeyFile = account.KeyFile
+		}
+		if sender.DIDType < 0 {
+			sender.DIDType = account.DIDType
+		}
+
+		// Use the sender's own node URL from file, then the file's, if not overridden
+		senderNode = account.NodeName
+		if rubixNode == "" {
+			rubixNode = account.RubixNodeURL
+		}
+		if rubixNode == "" {
+			rubixNode = accountsFile.RubixNodeURL
+		}
+	} else {
+		// Standard mode - use flags or env
+		finalSenderDID = *f.senderDID
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfigWithOverrides(rubixNode, finalSenderDID)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// In multi-node mode, use the Rubix URL of the node holding the sender
+	if senderNode == "" {
+		if nodes := resolveNodesOrExit(cfg, *f.nodes); len(nodes) > 0 {
+			senderNode, err = cfg.FindDIDNode(cfg.SenderDID, nodes)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if senderNode != "" {
+		rubixNodeURL := cfg.RubixNodeURL
+		cfg = cfg.ForNode(senderNode)
+		if rubixNode != "" {
+			cfg.RubixNodeURL = rubixNodeURL
+		}
+	}
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("Configuration error: %v\n", err)
+		fmt.Println("\nPlease set the following environment variables:")
+		fmt.Println("  RUBIX_NODE_URL  - Rubix node URL")
+		fmt.Println("  SENDER_DID      - Your DID")
+		fmt.Println("\nOr use command-line flags:")
+		fmt.Println("  --from-file accounts.json --sender-index 0")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	sender.Config = cfg
+	return sender
+}
+
+// resolveSignerKindOrExit picks the signer the sender's DID type requires
+// when none was given explicitly, querying the node for the type if unknown
+func (s *transferSender) resolveSignerKindOrExit() {
+	if s.SignerKind != "" {
+		return
+	}
+
+	var err error
+	if s.DIDType < 0 {
+		s.DIDType, err = rubix.GetDIDType(newRubixClientOrExit(s.Config, s.Config.RubixNodeURL), s.Config.SenderDID)
+		if err != nil {
+			fmt.Printf("Error: could not determine the sender's DID type: %v\n", err)
+			fmt.Println("Pass --did-type or --signer to choose the signature payload")
+			os.Exit(exitCode(err))
+		}
+	}
+	s.SignerKind, err = rubix.SignerKindForDIDType(s.DIDType)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Sender DID type: %s (signing with %s)\n", rubix.DIDTypeName(s.DIDType), s.SignerKind)
+}
+
+// newSignerOrExit builds the sender's signer and describes it for display
+func (s *transferSender) newSignerOrExit(inMemory bool) (rubix.Signer, string) {
+	signer, desc, err := newTransferSigner(s.Config, s.SignerKind, s.KeyFile, inMemory)
+	if err != nil {
+		if s.DIDType >= 0 {
+			fmt.Printf("Error: %s DID %s: %v\n", rubix.DIDTypeName(s.DIDType), s.Config.SenderDID, err)
+		} else {
+			fmt.Printf("Error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+	return signer, desc
+}
+
+// newTransferSigner builds the signer for the sender DID and describes it for
+// display. Its signatures, and the reconstruction of an in-memory share, are
+// recorded in the audit log.
+func newTransferSigner(cfg *config.Config, kind, keyFile string, inMemory bool) (rubix.Signer, string, error) {
+	signer, desc, err := loadTransferSigner(cfg, kind, keyFile, inMemory)
+	if err != nil {
+		return nil, "", err
+	}
+	auditLog, err := openAuditLog(cfg)
+	if err != nil || auditLog == nil {
+		if err != nil {
+			rubix.CloseSigner(signer)
+		}
+		return signer, desc, err
+	}
+
+	kind, _ = rubix.ParseSignerKind(kind)
+	entry := audit.Entry{Command: auditCommand(), DID: cfg.SenderDID, Signer: kind, Source: desc}
+	if inMemory && kind != rubix.SignerECDSA {
+		if err := auditLog.Append(audit.Entry{Operation: audit.OpReconstruct, Command: entry.Command, DID: cfg.SenderDID,
+			Source: rubix.ShareSource(signer), Output: "memory"}); err != nil {
+			rubix.CloseSigner(signer)
+			return nil, "", err
+		}
+	}
+	return &audit.Signer{Signer: signer, Log: auditLog, Entry: entry}, desc, nil
+}
+
+// loadTransferSigner builds the signer for the sender DID and describes it for display.
+// The image signature comes from the in-memory reconstruction, the vault or
+// pvtShare.png, in that order of preference.
+func loadTransferSigner(cfg *config.Config, kind, keyFile string, inMemory bool) (rubix.Signer, string, error) {
+	kind, err := rubix.ParseSignerKind(kind)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var image rubix.Signer
+	var desc string
+	if kind != rubix.SignerECDSA {
+		if inMemory {
+			didImagePath, pubSharePath, err := cfg.GetNLSSImagePaths(cfg.SenderDID)
+			if err != nil {
+				return nil, "", err
+			}
+			image, err = rubix.NewReconstructedSigner(didImagePath, pubSharePath)
+			if err != nil {
+				return nil, "", err
+			}
+			desc = "in-memory private share"
+		} else if v := openVaultOrExit(cfg); v != nil {
+			image, err = rubix.NewVaultSigner(v, cfg.SenderDID)
+			if err != nil {
+				return nil, "", err
+			}
+			desc = "encrypted vault " + v.Path(cfg.SenderDID)
+		} else {
+			pvtSharePath := filepath.Join(cfg.NLSSOutputDir, cfg.SenderDID, "pvtShare.png")
+			if _, err := os.Stat(pvtSharePath); err != nil {
+				return nil, "", fmt.Errorf("%s signing needs the private share, but %s is missing (run break-nlss or use --in-memory)", kind, pvtSharePath)
+			}
+			image = &rubix.NLSSFileSigner{PvtSharePath: pvtSharePath}
+			desc = pvtSharePath
+		}
+		if kind == rubix.SignerNLSS {
+			return image, desc, nil
+		}
+	}
+
+	// Fall back to the key the node keeps in the DID folder
+	if keyFile == "" {
+		if keyFile, err = cfg.GetNLSSPvtKeyPath(cfg.SenderDID); err != nil {
+			rubix.CloseSigner(image)
+			return nil, "", fmt.Errorf("%s signing needs an ECDSA private key: pass --key-file (%v)", kind, err)
+		}
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		rubix.CloseSigner(image)
+		return nil, "", fmt.Errorf("%s signing needs an ECDSA private key, but %s is missing (pass --key-file)", kind, keyFile)
+	}
+	key, err := rubix.NewECDSASignerFromPEM(keyFile, cfg.PrivateKeyPassword)
+	if err != nil {
+		rubix.CloseSigner(image)
+		return nil, "", err
+	}
+	if kind == rubix.SignerECDSA {
+		return key, "ECDSA key " + keyFile, nil
+	}
+	return &rubix.CombinedSigner{Image: image, Key: key}, desc + " + ECDSA key " + keyFile, nil
+}
+
+func runBalance() {
+	balanceCmd := flag.NewFlagSet("balance", flag.ExitOnError)
+
+	did := balanceCmd.String("did", "", "DID to query (default: from env SENDER_DID)")
+	rubixNode := balanceCmd.String("rubix-node", "", "Rubix node URL, host:port or https://...; comma-separated for failover (default: from env or localhost:20006)")
+
+	balanceCmd.Parse(os.Args[2:])
+
+	// Load configuration
+	cfg, err := config.LoadConfigWithOverrides(*rubixNode, *did)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Use SENDER_DID if --did not provided
+	queryDID := *did
+	if queryDID == "" {
+		queryDID = cfg.SenderDID
+	}
+
+	if queryDID == "" {
+		fmt.Println("Error: --did is required or set SENDER_DID environment variable")
+		balanceCmd.Usage()
+		os.Exit(1)
+	}
+
+	fmt.Printf("Querying balance for DID: %s\n", queryDID)
+	fmt.Printf("Rubix Node: %s\n\n", cfg.RubixNodeURL)
+
+	// Get balance
+	response, err := newRubixClientOrExit(cfg, cfg.RubixNodeURL).GetBalance(queryDID)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Balance: %.2f RBT\n", response.AccountInfo[0].RBTAmount)
+}
+
+func runListDIDs() {
+	listCmd := flag.NewFlagSet("list-dids", flag.ExitOnError)
+
+	rubixNode := listCmd.String("rubix-node", "", "Rubix node URL, host:port or https://...; comma-separated for failover (default: from env or localhost:20006)")
+
+	listCmd.Parse(os.Args[2:])
+
+	// Load configuration
+	cfg, err := config.LoadConfigWithOverrides(*rubixNode, "")
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Fetching all DIDs from: %s\n\n", cfg.RubixNodeURL)
+
+	// Get all DIDs
+	client := newRubixClientOrExit(cfg, cfg.RubixNodeURL)
+	response, err := client.GetAllDID()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Status: %v\n", response.Status)
+	fmt.Printf("Message: %s\n", response.Message)
+	fmt.Printf("Total DIDs: %d\n\n", len(response.AccountInfo))
+
+	fmt.Println("Account Information:")
+	fmt.Println("====================")
+	for i, account := range response.AccountInfo {
+		fmt.Printf("\n[%d] DID: %s\n", i+1, account.DID)
+		fmt.Printf("    Type: %d\n", account.DIDType)
+		fmt.Printf("    RBT Amount: %.2f\n", account.RBTAmount)
+		fmt.Printf("    Pledged: %.2f | Locked: %.2f | Pinned: %.2f\n",
+			account.PledgedRBT, account.LockedRBT, account.PinnedRBT)
+	}
+}
+
+func runHistory() {
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+
+	did := historyCmd.String("did", "", "DID to query (default: from env SENDER_DID)")
+	rubixNode := historyCmd.String("rubix-node", "", "Rubix node URL, host:port or https://...; comma-separated for failover (default: from env or localhost:20006)")
+	direction := historyCmd.String("direction", "all", "Transactions to show: sent, received or all")
+	from := historyCmd.String("from", "", "First day to include (YYYY-MM-DD)")
+	to := historyCmd.String("to", "", "Last day to include (YYYY-MM-DD)")
+	peer := historyCmd.String("peer", "", "Only transactions with this counterparty DID")
+	comment := historyCmd.String("comment", "", "Only transactions whose comment contains this text")
+	format := historyCmd.String("format", "table", "Output format: table, json or csv")
+	output := historyCmd.String("output", "", "File to write to (default: stdout)")
+
+	historyCmd.Parse(os.Args[2:])
+
+	cfg, err := config.LoadConfigWithOverrides(*rubixNode, *did)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	query := rubix.HistoryQuery{DID: *did, Peer: *peer, Comment: *comment}
+	if query.DID == "" {
+		query.DID = cfg.SenderDID
+	}
+	if query.DID == "" {
+		fmt.Println("Error: --did is required or set SENDER_DID environment variable")
+		historyCmd.Usage()
+		os.Exit(1)
+	}
+	switch *direction {
+	case rubix.DirectionSent, rubix.DirectionReceived:
+		query.Direction = *direction
+	case "all":
+	default:
+		fmt.Printf("Error: invalid --direction %q (expected sent, received or all)\n", *direction)
+		os.Exit(1)
+	}
+	if *format != "table" && *format != "json" && *format != "csv" {
+		fmt.Printf("Error: invalid --format %q (expected table, json or csv)\n", *format)
+		os.Exit(1)
+	}
+	query.From = parseDateOrExit("--from", *from)
+	query.To = parseDateOrExit("--to", *to)
+
+	client := newRubixClientOrExit(cfg, cfg.RubixNodeURL)
+	entries, err := client.TransactionHistory(context.Background(), query)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		err = writeHistoryJSON(w, entries)
+	case "csv":
+		err = writeHistoryCSV(w, entries)
+	default:
+		fmt.Fprintf(w, "Transactions of %s (%s)\n\n", query.DID, cfg.RubixNodeURL)
+		err = writeHistoryTable(w, entries)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *output != "" {
+		fmt.Printf("✓ %d transactions written to: %s\n", len(entries), *output)
+	}
+}
+
+// parseDateOrExit parses a YYYY-MM-DD flag value; empty means no bound
+func parseDateOrExit(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		fmt.Printf("Error: invalid %s %q (expected YYYY-MM-DD)\n", name, value)
+		os.Exit(1)
+	}
+	return t
+}
+
+// signedAmount shows sent amounts as negative
+func signedAmount(e rubix.HistoryEntry) float64 {
+	if e.Direction == rubix.DirectionSent {
+		return -e.Amount
+	}
+	return e.Amount
+}
+
+func writeHistoryTable(w io.Writer, entries []rubix.HistoryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tDIRECTION\tAMOUNT\tPEER\tCOMMENT\tTRANSACTION ID")
+	var sent, received float64
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%+.3f\t%s\t%s\t%s\n",
+			e.DateTime.Local().Format("2006-01-02 15:04"), e.Direction, signedAmount(e), e.Peer, e.Comment, e.TransactionID)
+		switch e.Direction {
+		case rubix.DirectionSent:
+			sent += e.Amount
+		case rubix.DirectionReceived:
+			received += e.Amount
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d transactions | Received: %.3f RBT | Sent: %.3f RBT | Net: %+.3f RBT\n",
+		len(entries), received, sent, received-sent)
+	return err
+}
+
+func writeHistoryJSON(w io.Writer, entries []rubix.HistoryEntry) error {
+	if entries == nil {
+		entries = []rubix.HistoryEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func writeHistoryCSV(w io.Writer, entries []rubix.HistoryEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "direction", "amount", "peer", "sender_did", "receiver_did", "comment", "transaction_id", "block_id"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.DateTime.Format(time.RFC3339),
+			e.Direction,
+			strconv.FormatFloat(signedAmount(e), 'f', -1, 64),
+			e.Peer,
+			e.SenderDID,
+			e.ReceiverDID,
+			e.Comment,
+			e.TransactionID,
+			e.BlockID,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func runReceipts() {
+	receiptsCmd := flag.NewFlagSet("receipts", flag.ExitOnError)
+
+	ledgerPath := receiptsCmd.String("ledger", "", "Ledger file (default: from env LEDGER_PATH or the per-user ledger)")
+	did := receiptsCmd.String("did", "", "Only transfers sent or received by this DID")
+	id := receiptsCmd.String("id", "", "Only the transfer with this transfer ID or request ID")
+	requestID := receiptsCmd.String("request-id", "", "Only the transfer with this request ID")
+	txnID := receiptsCmd.String("txn-id", "", "Only the transfer with this transaction ID")
+	state := receiptsCmd.String("state", "", "Only transfers in this state: initiating, initiated, signed, submitted, pending, confirmed, failed or abandoned")
+	from := receiptsCmd.String("from", "", "First day to include (YYYY-MM-DD)")
+	to := receiptsCmd.String("to", "", "Last day to include (YYYY-MM-DD)")
+	format := receiptsCmd.String("format", "table", "Output format: table, json or csv")
+	output := receiptsCmd.String("output", "", "File to write to (default: stdout)")
+
+	receiptsCmd.Parse(os.Args[2:])
+
+	if *format != "table" && *format != "json" && *format != "csv" {
+		fmt.Printf("Error: invalid --format %q (expected table, json or csv)\n", *format)
+		os.Exit(1)
+	}
+	path := *ledgerPath
+	if path == "" {
+		cfg, err := config.LoadConfigWithOverrides("", "")
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		path = cfg.LedgerPath
+	}
+	if path == "" {
+		fmt.Println("Error: the ledger is disabled (LEDGER_PATH=off); pass --ledger")
+		os.Exit(1)
+	}
+
+	entries, err := storage.LoadLedger(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	receipts := storage.FilterReceipts(storage.Receipts(entries), storage.ReceiptQuery{
+		DID:           *did,
+		ID:            *id,
+		RequestID:     *requestID,
+		TransactionID: *txnID,
+		State:         *state,
+		From:          parseDateOrExit("--from", *from),
+		To:            parseDateOrExit("--to", *to),
+	})
+
+	if len(receipts) == 0 && (*id != "" || *requestID != "" || *txnID != "") {
+		fmt.Printf("Error: no matching transfer in %s\n", path)
+		os.Exit(exitNotFound)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch {
+	case *format == "json":
+		err = writeReceiptsJSON(w, receipts)
+	case *format == "csv":
+		err = writeReceiptsCSV(w, receipts)
+	case len(receipts) == 1 && (*id != "" || *requestID != "" || *txnID != ""):
+		err = writeReceipt(w, receipts[0], entries)
+	default:
+		fmt.Fprintf(w, "Transfers in %s\n\n", path)
+		err = writeReceiptsTable(w, receipts)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *output != "" {
+		fmt.Printf("✓ %d receipts written to: %s\n", len(receipts), *output)
+	}
+}
+
+func writeReceiptsTable(w io.Writer, receipts []storage.LedgerEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CREATED\tSTATE\tAMOUNT\tRECEIVER\tCOMMENT\tREQUEST ID")
+	for _, r := range receipts {
+		fmt.Fprintf(tw, "%s\t%s\t%.3f\t%s\t%s\t%s\n",
+			r.CreatedAt.Local().Format("2006-01-02 15:04"), r.State, r.Amount, r.ReceiverDID, r.Comment, r.RequestID)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d transfers\n", len(receipts))
+	return err
+}
+
+// writeReceipt shows everything recorded about one transfer, with the state after each phase
+func writeReceipt(w io.Writer, r storage.LedgerEntry, entries []storage.LedgerEntry) error {
+	fmt.Fprintf(w, "Transfer ID:    %s\n", r.TransferID())
+	fmt.Fprintf(w, "Request ID:     %s\n", r.RequestID)
+	fmt.Fprintf(w, "Transaction ID: %s\n", r.TransactionID)
+	fmt.Fprintf(w, "State:          %s\n", r.State)
+	fmt.Fprintf(w, "Sender:         %s\n", r.SenderDID)
+	fmt.Fprintf(w, "Receiver:       %s\n", r.ReceiverDID)
+	fmt.Fprintf(w, "Amount:         %.3f RBT\n", r.Amount)
+	fmt.Fprintf(w, "Comment:        %s\n", r.Comment)
+	fmt.Fprintf(w, "Node:           %s\n", r.NodeURL)
+	fmt.Fprintf(w, "Hash:           %s\n", r.Hash)
+	fmt.Fprintf(w, "Signature:      %x\n", r.Signature)
+	fmt.Fprintf(w, "Pixels:         %d bytes\n", len(r.Pixels))
+	if r.Message != "" {
+		fmt.Fprintf(w, "Message:        %s\n", r.Message)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(w, "Error:          %s\n", r.Error)
+	}
+	fmt.Fprintf(w, "Created:        %s\n", r.CreatedAt.Local().Format(time.RFC3339))
+	fmt.Fprintln(w, "\nTrail:")
+	for _, e := range storage.Trail(entries, r) {
+		fmt.Fprintf(w, "  %s  %s\n", e.Timestamp.Local().Format(time.RFC3339), e.State)
+	}
+	return nil
+}
+
+func writeReceiptsJSON(w io.Writer, receipts []storage.LedgerEntry) error {
+	if receipts == nil {
+		receipts = []storage.LedgerEntry{}
+	}
+	data, err := json.MarshalIndent(receipts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal receipts: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func writeReceiptsCSV(w io.Writer, receipts []storage.LedgerEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"created_at", "updated_at", "state", "amount", "sender_did", "receiver_did", "comment", "request_id", "transaction_id", "node_url", "hash", "signature", "error"})
+	for _, r := range receipts {
+		cw.Write([]string{
+			r.CreatedAt.Format(time.RFC3339),
+			r.Timestamp.Format(time.RFC3339),
+			r.State,
+			strconv.FormatFloat(r.Amount, 'f', -1, 64),
+			r.SenderDID,
+			r.ReceiverDID,
+			r.Comment,
+			r.RequestID,
+			r.TransactionID,
+			r.NodeURL,
+			r.Hash,
+			hex.EncodeToString(r.Signature),
+			r.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func runExportDIDs() {
+	exportCmd := flag.NewFlagSet("export-dids", flag.ExitOnError)
+
+	output := exportCmd.String("output", "accounts.json", "Output file path")
+	rubixNode := exportCmd.String("rubix-node", "", "Rubix node URL, host:port or https://...; comma-separated for failover (default: from env or localhost:20006)")
+	minBalance := exportCmd.Float64("min-balance", 0.0, "Minimum balance to include (default: 0, only non-zero balances)")
+	nodesFlag := exportCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH, e.g. 'bulk0*' (default: from env NLSS_NODES)")
+
+	exportCmd.Parse(os.Args[2:])
+
+	// Load configuration
+	cfg, err := config.LoadConfigWithOverrides(*rubixNode, "")
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	nodes := resolveNodesOrExit(cfg, *nodesFlag)
+
+	// Group nodes by Rubix URL so each URL is queried once
+	var urls []string
+	urlNodes := make(map[string][]string)
+	if len(nodes) == 0 {
+		urls = []string{cfg.RubixNodeURL}
+	}
+	for _, node := range nodes {
+		url := cfg.ForNode(node).RubixNodeURL
+		if _, ok := urlNodes[url]; !ok {
+			urls = append(urls, url)
+		}
+		urlNodes[url] = append(urlNodes[url], node)
+	}
+
+	fmt.Printf("Minimum balance filter: %.2f RBT\n\n", *minBalance)
+
+	// Filter DIDs with balance > minBalance
+	var accounts []storage.DIDAccount
+	for _, url := range urls {
+		fmt.Printf("Fetching DIDs from: %s\n", url)
+
+		// Get all DIDs
+		client := newRubixClientOrExit(cfg, url)
+		response, err := client.GetAllDID()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		fmt.Printf("Total DIDs on node: %d\n", len(response.AccountInfo))
+
+		for _, account := range response.AccountInfo {
+			if account.RBTAmount <= *minBalance {
+				continue
+			}
+
+			didAccount := storage.DIDAccount{
+				DID:        account.DID,
+				Balance:    account.RBTAmount,
+				DIDType:    account.DIDType,
+				PledgedRBT: account.PledgedRBT,
+				LockedRBT:  account.LockedRBT,
+				PinnedRBT:  account.PinnedRBT,
+				UpdatedAt:  time.Now(),
+			}
+
+			// Tie the DID to the node folder that holds it
+			if len(nodes) > 0 {
+				didAccount.RubixNodeURL = url
+				if node, err := cfg.FindDIDNode(account.DID, urlNodes[url]); err == nil {
+					didAccount.NodeName = node
+				}
+			}
+
+			accounts = append(accounts, didAccount)
+		}
+	}
+	fmt.Println()
+
+	fmt.Printf("DIDs with balance > %.2f: %d\n\n", *minBalance, len(accounts))
+
+	if len(accounts) == 0 {
+		fmt.Println("No DIDs found with the specified balance criteria.")
+		os.Exit(0)
+	}
+
+	// Save to file
+	err = storage.SaveAccountsToFile(*output, accounts, cfg.RubixNodeURL)
+	if err != nil {
+		fmt.Printf("Error saving to file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Successfully exported %d DIDs to: %s\n\n", len(accounts), *output)
+
+	// Print summary
+	fmt.Println("Exported Accounts:")
+	fmt.Println("==================")
+	for i, account := range accounts {
+		fmt.Printf("[%d] DID: %s\n", i, account.DID)
+		if account.NodeName != "" {
+			fmt.Printf("    Node: %s (%s)\n", account.NodeName, account.RubixNodeURL)
+		}
+		fmt.Printf("    Balance: %.2f RBT\n", account.Balance)
+		fmt.Printf("    Pledged: %.2f | Locked: %.2f | Pinned: %.2f\n\n",
+			account.PledgedRBT, account.LockedRBT, account.PinnedRBT)
+	}
+
+	fmt.Println("Usage:")
+	fmt.Printf("  ./break-nlss transfer --from-file %s --sender-index 0 --receiver <DID> --amount <AMOUNT>\n", *output)
+}
+
+func runGenerateKey() {
+	genCmd := flag.NewFlagSet("generate-key", flag.ExitOnError)
+
+	outputDir := genCmd.String("output", "./preset", "Output directory for key files")
+
+	genCmd.Parse(os.Args[2:])
+
+	fmt.Printf("Generating new EC key pair (P-256)...\n")
+	fmt.Printf("Output directory: %s\n\n", *outputDir)
+
+	// Ensure output directory exists
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		fmt.Printf("Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	privateKeyPath := fmt.Sprintf("%s/privatekey.pem", *outputDir)
+	publicKeyPath := fmt.Sprintf("%s/publickey.pem", *outputDir)
+
+	// Generate keys
+	_, err := rubix.GenerateAndSaveKeys(privateKeyPath, publicKeyPath)
+	if err != nil {
+		fmt.Printf("Error generating keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Key pair generated successfully!\n")
+	fmt.Printf("  Private key: %s\n", privateKeyPath)
+	fmt.Printf("  Public key: %s\n", publicKeyPath)
+	fmt.Println("\nIMPORTANT: Keep your private key secure and never share it!")
+}
+
+func runBreakNLSS() {
+	breakCmd := flag.NewFlagSet("break-nlss", flag.ExitOnError)
+
+	didInput := breakCmd.String("did", "", "DID string or path to file containing DIDs")
+	all := breakCmd.Bool("all", false, "Process every DID folder found under the NLSS node directory")
+	workers := breakCmd.Int("workers", 1, "Number of DIDs to process concurrently")
+	reportPath := breakCmd.String("report", "break-nlss-report.jsonl", "JSON lines report/checkpoint file (empty to disable)")
+	resume := breakCmd.Bool("resume", false, "Skip DIDs the report marks as completed and verified")
+	nodesFlag := breakCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH, e.g. 'bulk0*' (default: from env NLSS_NODES)")
+
+	breakCmd.Parse(os.Args[2:])
+
+	// Validate required flags
+	if *didInput != "" && *all {
+		fmt.Println("Error: --did and --all cannot be used together")
+		breakCmd.Usage()
+		os.Exit(1)
+	}
+
+	if *didInput == "" && !*all {
+		fmt.Println("Error: --did or --all is required")
+		fmt.Println("\nUsage:")
+		fmt.Println("  Single DID:")
+		fmt.Println("    break-nlss break-nlss --did bafybmifeh7csi6wuuwqd3c7cxcwk5k3e3nd2f73x2hxa2teojhkd6ztdse")
+		fmt.Println("  Multiple DIDs from file:")
+		fmt.Println("    break-nlss break-nlss --did dids.txt --workers 8")
+		fmt.Println("  Every DID on the node:")
+		fmt.Println("    break-nlss break-nlss --all --workers 8")
+		breakCmd.Usage()
+		os.Exit(1)
+	}
+
+	if *workers < 1 {
+		fmt.Println("Error: --workers must be at least 1")
+		breakCmd.Usage()
+		os.Exit(1)
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	nodes := resolveNodesOrExit(cfg, *nodesFlag)
+	v := openVaultOrExit(cfg)
+	if v != nil {
+		fmt.Printf("Writing private shares to encrypted vault: %s\n\n", v.Dir())
+	}
+
+	// Determine if input is discovery, a file or a single DID
+	var dids []string
+	didNodes := make(map[string]string)
+	if *all {
+		folders := discoverDIDFoldersOrExit(cfg, nodes)
+
+		incomplete := 0
+		for _, folder := range folders {
+			if !folder.Complete() {
+				fmt.Printf("⚠ Skipping %s: missing %s\n", folder.DID, strings.Join(folder.Missing(cfg), ", "))
+				incomplete++
+				continue
+			}
+			// Both copies would be written to the same output path
+			if node, ok := didNodes[folder.DID]; ok {
+				fmt.Printf("⚠ Skipping %s on %s: already found on %s\n", folder.DID, folder.Node, node)
+				continue
+			}
+			dids = append(dids, folder.DID)
+			didNodes[folder.DID] = folder.Node
+		}
+		fmt.Printf("Discovered %d DIDs to process (%d incomplete folders skipped)\n\n", len(dids), incomplete)
+	} else if _, err := os.Stat(*didInput); err == nil {
+		// It's a file, read DIDs from it
+		fmt.Printf("Reading DIDs from file: %s\n", *didInput)
+		dids, err = readDIDsFromFile(*didInput)
+		if err != nil {
+			fmt.Printf("Error reading DIDs from file: %v\n", err)
+			os.Exit(1)
+		}
+		dids = uniqueDIDs(dids)
+		fmt.Printf("Found %d DIDs to process\n\n", len(dids))
+	} else {
+		// It's a single DID
+		dids = []string{strings.TrimSpace(*didInput)}
+	}
+
+	// Skip DIDs that a previous run already completed
+	skipped := 0
+	if *resume {
+		if *reportPath == "" {
+			fmt.Println("Error: --resume requires --report")
+			breakCmd.Usage()
+			os.Exit(1)
+		}
+
+		records, err := storage.LoadBreakReport(*reportPath)
+		if err != nil {
+			fmt.Printf("Error loading report: %v\n", err)
+			os.Exit(1)
+		}
+
+		var remaining []string
+		for _, did := range dids {
+			if breakNLSSCompleted(cfg, v, records, did) {
+				skipped++
+				continue
+			}
+			remaining = append(remaining, did)
+		}
+		dids = remaining
+		fmt.Printf("Resuming from %s: skipping %d completed DIDs, %d remaining\n\n", *reportPath, skipped, len(dids))
+	}
+
+	var report *storage.BreakReport
+	if *reportPath != "" {
+		report, err = storage.OpenBreakReport(*reportPath)
+		if err != nil {
+			fmt.Printf("Error opening report: %v\n", err)
+			os.Exit(1)
+		}
+		defer report.Close()
+	}
+
+	if *workers > len(dids) {
+		*workers = len(dids)
+	}
+	if *workers > 1 {
+		fmt.Printf("Processing with %d workers\n\n", *workers)
+	}
+
+	start := time.Now()
+	// Tie each DID to the node it lives on
+	jobs := make([]breakNLSSJob, len(dids))
+	for i, did := range dids {
+		jobs[i] = breakNLSSJob{DID: did, Config: cfg, Vault: v}
+		if len(nodes) == 0 {
+			continue
+		}
+
+		node, ok := didNodes[did]
+		if !ok {
+			node, err = cfg.FindDIDNode(did, nodes)
+			if err != nil {
+				jobs[i].Err = err
+				continue
+			}
+			// Copies on other nodes would be written to the same output path
+			if found := cfg.DIDNodes(did, nodes); len(found) > 1 {
+				fmt.Printf("⚠ %s is on %s; using %s\n", did, strings.Join(found, ", "), node)
+			}
+		}
+		jobs[i].Config = cfg.ForNode(node)
+	}
+
+	auditLog := openAuditLogOrExit(cfg)
+	results := runBreakNLSSPool(jobs, *workers, func(r breakNLSSResult, completed, total int) {
+		printBreakNLSSResult(r, completed, total)
+		if auditLog != nil {
+			if err := auditLog.Append(r.AuditEntry()); err != nil {
+				fmt.Printf("Warning: failed to update audit log: %v\n\n", err)
+			}
+		}
+		if report == nil {
+			return
+		}
+		if err := report.Append(r.Record()); err != nil {
+			fmt.Printf("Warning: failed to update report: %v\n\n", err)
+		}
+	})
+
+	// Print summary in input order
+	successCount := 0
 	failCount := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failCount++
+		} else {
+			successCount++
+		}
+	}
+
+	fmt.Println("============================================")
+	fmt.Println("Summary:")
+	fmt.Printf("  Total DIDs: %d\n", len(dids)+skipped)
+	fmt.Printf("  Successful: %d\n", successCount)
+	fmt.Printf("  Failed: %d\n", failCount)
+	if *resume {
+		fmt.Printf("  Skipped (already completed): %d\n", skipped)
+	}
+	if report != nil {
+		fmt.Printf("  Report: %s\n", *reportPath)
+	}
+	fmt.Printf("  Elapsed: %s\n", time.Since(start).Round(time.Millisecond))
+	if len(results) > 1 {
+		fmt.Println("\nResults:")
+		for i, r := range results {
+			if r.Err != nil {
+				fmt.Printf("  [%d] ❌ %s: %v\n", i+1, r.DID, r.Err)
+			} else {
+				fmt.Printf("  [%d] ✓ %s\n", i+1, r.DID)
+			}
+		}
+	}
+	fmt.Println("\nIMPORTANT: Keep your private shares secure and never share them!")
+}
+
+// breakNLSSJob is one DID to reconstruct, with the configuration of the node it lives on
+type breakNLSSJob struct {
+	DID    string
+	Config *config.Config
+	Vault  *vault.Vault // when set, the private share is stored encrypted instead of as PNG
+	Err    error        // set when the DID could not be tied to a node
+}
+
+// breakNLSSResult holds the outcome of reconstructing one DID's private share
+type breakNLSSResult struct {
+	DID          string
+	Node         string
+	DIDImagePath string
+	PubSharePath string
+	OutputPath   string
+	Verified     bool
+	Err          error
+}
+
+// Record converts the result into a report line
+func (r breakNLSSResult) Record() storage.BreakReportRecord {
+	record := storage.BreakReportRecord{
+		DID:        r.DID,
+		Node:       r.Node,
+		Status:     storage.BreakStatusSuccess,
+		OutputPath: r.OutputPath,
+		Verified:   r.Verified,
+	}
+	if r.Err != nil {
+		record.Status = storage.BreakStatusFailed
+		record.Error = r.Err.Error()
+	}
+	return record
+}
+
+// AuditEntry converts the result into an audit log entry
+func (r breakNLSSResult) AuditEntry() audit.Entry {
+	e := audit.Entry{Operation: audit.OpReconstruct, Command: "break-nlss", DID: r.DID, Output: r.OutputPath}
+	if r.PubSharePath != "" {
+		e.Source = r.DIDImagePath + " + " + r.PubSharePath
+	}
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+	}
+	return e
+}
+
+// breakNLSSCompleted reports whether a DID can be skipped on resume: its last
+// report record is a verified success and the private share is still on disk
+func breakNLSSCompleted(cfg *config.Config, v *vault.Vault, records map[string]storage.BreakReportRecord, did string) bool {
+	record, ok := records[did]
+	if !ok || !record.Completed() {
+		return false
+	}
+	if v != nil {
+		return v.Exists(did)
+	}
+
+	outputPath, err := cfg.GetNLSSOutputPath(did)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(outputPath)
+	return err == nil
+}
+
+// runBreakNLSSPool reconstructs private shares with a pool of workers.
+// onDone is called for each result as it completes, always from the calling
+// goroutine; results are returned in input order.
+func runBreakNLSSPool(jobs []breakNLSSJob, workers int, onDone func(r breakNLSSResult, completed, total int)) []breakNLSSResult {
+	results := make([]breakNLSSResult, len(jobs))
+	queue := make(chan int)
+	done := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				// Each worker only writes its own slot
+				results[i] = breakNLSSForDID(jobs[i])
+				done <- i
+			}
+		}()
+	}
+
+	go func() {
+		for i := range jobs {
+			queue <- i
+		}
+		close(queue)
+		wg.Wait()
+		close(done)
+	}()
+
+	// Reporting happens on this goroutine only, so output never interleaves
+	completed := 0
+	for i := range done {
+		completed++
+		onDone(results[i], completed, len(jobs))
+	}
+
+	return results
+}
+
+// breakNLSSForDID resolves the image paths for a DID and reconstructs its private share
+func breakNLSSForDID(job breakNLSSJob) breakNLSSResult {
+	if job.Err != nil {
+		return breakNLSSResult{DID: job.DID, Err: job.Err}
+	}
+
+	cfg, did := job.Config, job.DID
+	result := breakNLSSResult{DID: did, Node: cfg.NLSSNodeName}
+
+	// Get input image paths from config
+	didImagePath, pubSharePath, err := cfg.GetNLSSImagePaths(did)
+	if err != nil {
+		result.Err = fmt.Errorf("error constructing paths: %w", err)
+		return result
+	}
+	result.DIDImagePath = didImagePath
+	result.PubSharePath = pubSharePath
+
+	// Get output path from the vault or config
+	var outputPath string
+	if job.Vault != nil {
+		outputPath = job.Vault.Path(did)
+	} else {
+		outputPath, err = cfg.GetNLSSOutputPath(did)
+		if err != nil {
+			result.Err = fmt.Errorf("error constructing output path: %w", err)
+			return result
+		}
+	}
+	result.OutputPath = outputPath
+
+	// Check if input files exist
+	if _, err := os.Stat(didImagePath); os.IsNotExist(err) {
+		result.Err = fmt.Errorf("DID image file not found: %s", didImagePath)
+		return result
+	}
+
+	if _, err := os.Stat(pubSharePath); os.IsNotExist(err) {
+		result.Err = fmt.Errorf("public share file not found: %s", pubSharePath)
+		return result
+	}
+
+	// Run the BreakNLSS algorithm; success means VerifyPVT passed
+	if job.Vault != nil {
+		result.Err = breakNLSSToVault(job.Vault, did, didImagePath, pubSharePath)
+	} else {
+		result.Err = nlss.BreakNLSSFromFiles(didImagePath, pubSharePath, outputPath)
+	}
+	result.Verified = result.Err == nil
+	return result
+}
+
+// breakNLSSToVault reconstructs a private share in memory and stores it encrypted
+func breakNLSSToVault(v *vault.Vault, did, didImagePath, pubSharePath string) error {
+	pvtBytes, err := nlss.ReconstructFromFiles(didImagePath, pubSharePath)
+	if err != nil {
+		return err
+	}
+	defer nlss.Wipe(pvtBytes)
+
+	return v.Store(did, pvtBytes)
+}
+
+// printBreakNLSSResult prints the outcome of one DID as it completes
+func printBreakNLSSResult(r breakNLSSResult, completed, total int) {
+	fmt.Printf("[%d/%d] Processing DID: %s\n", completed, total, r.DID)
+	fmt.Println("============================================")
+	if r.Node != "" {
+		fmt.Printf("  Node: %s\n", r.Node)
+	}
+	if r.DIDImagePath != "" {
+		fmt.Printf("  DID Image: %s\n", r.DIDImagePath)
+		fmt.Printf("  Public Share: %s\n", r.PubSharePath)
+	}
+	if r.OutputPath != "" {
+		fmt.Printf("  Output: %s\n", r.OutputPath)
+	}
+
+	if r.Err != nil {
+		fmt.Printf("❌ Error: %v\n\n", r.Err)
+		return
+	}
+
+	fmt.Printf("✓ Successfully reconstructed private share!\n")
+	fmt.Printf("  Saved to: %s\n\n", r.OutputPath)
+}
+
+func runScan() {
+	scanCmd := flag.NewFlagSet("scan", flag.ExitOnError)
+
+	output := scanCmd.String("output", "", "Write complete DIDs to this file (usable with break-nlss --did)")
+	nodesFlag := scanCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH, e.g. 'bulk0*' (default: from env NLSS_NODES)")
+
+	scanCmd.Parse(os.Args[2:])
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	nodes := resolveNodesOrExit(cfg, *nodesFlag)
+	if len(nodes) > 0 {
+		fmt.Printf("Scanning %d nodes under: %s\n\n", len(nodes), cfg.NLSSBasePath)
+	} else {
+		rubixDir, err := cfg.GetNLSSRubixDir()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Scanning: %s\n\n", rubixDir)
+	}
+
+	folders := discoverDIDFoldersOrExit(cfg, nodes)
+
+	var complete []string
+	var incomplete []config.DIDFolder
+	for _, folder := range folders {
+		if folder.Complete() {
+			complete = append(complete, folder.DID)
+		} else {
+			incomplete = append(incomplete, folder)
+		}
+	}
+
+	fmt.Printf("Complete DID folders: %d\n", len(complete))
+	i := 0
+	for _, folder := range folders {
+		if !folder.Complete() {
+			continue
+		}
+		i++
+		if len(nodes) > 0 {
+			fmt.Printf("  [%d] %s (%s)\n", i, folder.DID, folder.Node)
+		} else {
+			fmt.Printf("  [%d] %s\n", i, folder.DID)
+		}
+	}
+
+	if len(incomplete) > 0 {
+		fmt.Printf("\nIncomplete DID folders: %d\n", len(incomplete))
+		for _, folder := range incomplete {
+			fmt.Printf("  ❌ %s (missing %s)\n", folder.Path, strings.Join(folder.Missing(cfg), ", "))
+		}
+	}
+
+	if *output != "" {
+		data := strings.Join(complete, "\n")
+		if data != "" {
+			data += "\n"
+		}
+		if err := os.WriteFile(*output, []byte(data), 0644); err != nil {
+			fmt.Printf("Error writing DIDs file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n✓ Wrote %d DIDs to: %s\n", len(complete), *output)
+	}
+
+	fmt.Println("\nUsage:")
+	fmt.Println("  ./break-nlss break-nlss --all --workers 8")
+}
+
+func runGenerateNLSS() {
+	genCmd := flag.NewFlagSet("generate-nlss", flag.ExitOnError)
+
+	outputDir := genCmd.String("output", "", "Output directory for did.png, pubShare.png and pvtShare.png (required)")
+	seed := genCmd.String("seed", "", "Seed for reproducible shares (default: random)")
+
+	genCmd.Parse(os.Args[2:])
+
+	if *outputDir == "" {
+		fmt.Println("Error: --output is required")
+		genCmd.Usage()
+		os.Exit(1)
+	}
+
+	var source io.Reader
+	if *seed != "" {
+		fmt.Println("Generating DID image and shares from seed...")
+		source = nlss.NewSeededReader([]byte(*seed))
+	} else {
+		fmt.Println("Generating random DID image and shares...")
+	}
+
+	shares, err := nlss.GenerateDID(source)
+	if err != nil {
+		fmt.Printf("Error generating shares: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Check both share relations before anything is written
+	if !nlss.VerifyPVT(shares.DID, shares.Pub, shares.Pvt) {
+		fmt.Println("Error: generated private share failed verification")
+		os.Exit(1)
+	}
+	if !bytes.Equal(nlss.Combine2Shares(shares.Pvt, shares.Pub), shares.DID) {
+		fmt.Println("Error: combined shares do not reproduce the DID image")
+		os.Exit(1)
+	}
+
+	if err := nlss.SaveShares(shares, *outputDir); err != nil {
+		fmt.Printf("Error saving shares: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Shares generated and verified!\n")
+	fmt.Printf("  DID Image: %s\n", filepath.Join(*outputDir, nlss.DIDImageFileName))
+	fmt.Printf("  Public Share: %s\n", filepath.Join(*outputDir, nlss.PubShareFileName))
+	fmt.Printf("  Private Share: %s\n", filepath.Join(*outputDir, nlss.PvtShareFileName))
+	fmt.Println("\nIMPORTANT: Keep your private shares secure and never share them!")
+}
+
+func runSignMessage() {
+	signCmd := flag.NewFlagSet("sign-message", flag.ExitOnError)
+
+	did := signCmd.String("did", "", "DID to sign as (default: from env SENDER_DID)")
+	message := signCmd.String("message", "", "Message text to sign")
+	file := signCmd.String("file", "", "File to sign (instead of --message)")
+	output := signCmd.String("output", "message.sig.json", "Signature document to write")
+	embed := signCmd.Bool("embed", false, "Include the message in the document (always on for --message)")
+	signerFlag := signCmd.String("signer", rubix.SignerNLSS, "Signing method: nlss or nlss+ecdsa")
+	keyFile := signCmd.String("key-file", "", "EC private key PEM for nlss+ecdsa (default: pvtKey.pem in the DID folder; password from env PRIVATE_KEY_PASSWORD)")
+	inMemory := signCmd.Bool("in-memory", false, "Rebuild the private share in memory from did.png and pubShare.png instead of reading pvtShare.png")
+	nodesFlag := signCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH to locate the DID on (default: from env NLSS_NODES)")
+
+	signCmd.Parse(os.Args[2:])
+
+	data := readMessageOrExit(signCmd, *message, *file)
+	if *signerFlag == rubix.SignerECDSA {
+		fmt.Println("Error: messages must be signed with the private share (use nlss or nlss+ecdsa)")
+		os.Exit(1)
+	}
+
+	cfg := loadDIDConfigOrExit(*did, *nodesFlag)
+	if cfg.SenderDID == "" {
+		fmt.Println("Error: --did is required (or set SENDER_DID)")
+		signCmd.Usage()
+		os.Exit(1)
+	}
+
+	signer, signerDesc, err := newTransferSigner(cfg, *signerFlag, *keyFile, *inMemory)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer rubix.CloseSigner(signer)
+
+	fmt.Println("Signing Message:")
+	fmt.Println("================")
+	fmt.Printf("  DID: %s\n", cfg.SenderDID)
+	fmt.Printf("  Message: %d bytes\n", len(data))
+	fmt.Printf("  Signing: %s\n", signerDesc)
+
+	signed, err := auth.SignMessage(cfg.SenderDID, data, signer, *embed || *message != "")
+	if err != nil {
+		fmt.Printf("\n❌ Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	for i, did := range dids {
-		did = strings.TrimSpace(did)
-		if did == "" {
-			continue // Skip empty lines
+	if err := auth.SaveSignedMessage(*output, signed); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n✓ Message signed\n")
+	fmt.Printf("  Hash (SHA3-256): %s\n", signed.Hash)
+	fmt.Printf("  Signature document: %s\n", *output)
+}
+
+func runVerifyMessage() {
+	verifyCmd := flag.NewFlagSet("verify-message", flag.ExitOnError)
+
+	sigPath := verifyCmd.String("signature", "", "Signature document from sign-message (required)")
+	message := verifyCmd.String("message", "", "Message text (default: the message embedded in the document)")
+	file := verifyCmd.String("file", "", "Signed file (instead of --message)")
+	didImage := verifyCmd.String("did-image", "", "DID image (default: from the DID folder under NLSS_BASE_PATH)")
+	pubShare := verifyCmd.String("pub-share", "", "Public share image (default: from the DID folder under NLSS_BASE_PATH)")
+	pubKeyFile := verifyCmd.String("pub-key", "", "EC public key PEM; when given or found in the DID folder, the ECDSA signature is required (default: pubKey.pem in the DID folder)")
+	nodesFlag := verifyCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH to locate the DID on (default: from env NLSS_NODES)")
+
+	verifyCmd.Parse(os.Args[2:])
+
+	if *sigPath == "" {
+		fmt.Println("Error: --signature is required")
+		verifyCmd.Usage()
+		os.Exit(1)
+	}
+
+	signed, err := auth.LoadSignedMessage(*sigPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The message must be given unless the document embeds it
+	if *message != "" || *file != "" {
+		if err := signed.CheckMessage(readMessageOrExit(verifyCmd, *message, *file)); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
 		}
+	} else if signed.Message == "" {
+		fmt.Println("Error: the document does not embed its message; pass --message or --file")
+		os.Exit(1)
+	}
 
-		fmt.Printf("[%d/%d] Processing DID: %s\n", i+1, len(dids), did)
-		fmt.Println("============================================")
+	// Public material defaults to the DID folder on the node
+	didImagePath, pubSharePath, pubKeyPath := *didImage, *pubShare, *pubKeyFile
+	cfg, cfgErr := locateDID(loadDIDConfigOnlyOrExit(signed.DID), *nodesFlag)
+	if didImagePath == "" || pubSharePath == "" {
+		defaultDIDImage, defaultPubShare, err := cfg.GetNLSSImagePaths(signed.DID)
+		if cfgErr != nil {
+			err = cfgErr
+		}
+		if err != nil {
+			fmt.Printf("Error: %v (or pass --did-image and --pub-share)\n", err)
+			os.Exit(1)
+		}
+		if didImagePath == "" {
+			didImagePath = defaultDIDImage
+		}
+		if pubSharePath == "" {
+			pubSharePath = defaultPubShare
+		}
+	}
+	// A DID with a key on file must prove it: NLSS-only signatures can be
+	// forged by anyone holding the public images
+	if pubKeyPath == "" && cfgErr == nil {
+		if path, err := cfg.GetNLSSPubKeyPath(signed.DID); err == nil {
+			if _, err := os.Stat(path); err == nil {
+				pubKeyPath = path
+			}
+		}
+	}
+	if pubKeyPath == "" && signed.Signer == rubix.SignerCombined {
+		fmt.Printf("Error: no %s found for %s; pass --pub-key\n", cfg.NLSSPubKeyName, signed.DID)
+		os.Exit(1)
+	}
 
-		// Get input image paths from config
-		didImagePath, pubSharePath, err := cfg.GetNLSSImagePaths(did)
+	var pubKey *ecdsa.PublicKey
+	if pubKeyPath != "" {
+		pubKey, err = crypto.LoadPublicKeyFromPEM(pubKeyPath)
 		if err != nil {
-			fmt.Printf("❌ Error constructing paths: %v\n\n", err)
-			failCount++
-			continue
+			fmt.Printf("Error loading public key: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Println("Verifying Message:")
+	fmt.Println("==================")
+	fmt.Printf("  DID: %s\n", signed.DID)
+	fmt.Printf("  Signer: %s\n", signed.Signer)
+	fmt.Printf("  Signed At: %s\n", signed.SignedAt.Format(time.RFC3339))
+	fmt.Printf("  DID Image: %s\n", didImagePath)
+	fmt.Printf("  Public Share: %s\n", pubSharePath)
+	if pubKey != nil {
+		fmt.Printf("  Public Key: %s\n", pubKeyPath)
+	}
+
+	if err := signed.Verify(didImagePath, pubSharePath, pubKey); err != nil {
+		fmt.Printf("\n❌ Verification failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n✓ Signature valid: the message was signed by %s\n", signed.DID)
+	if signed.Message != "" && *message == "" && *file == "" {
+		fmt.Printf("  Message: %s\n", signed.Message)
+	}
+}
+
+func runAuthServer() {
+	serverCmd := flag.NewFlagSet("auth-server", flag.ExitOnError)
+
+	registryPath := serverCmd.String("registry", "auth-registry.json", "JSON file listing the DIDs allowed to log in")
+	listen := serverCmd.String("listen", ":8090", "Address to listen on")
+	challengeTTL := serverCmd.Duration("challenge-ttl", auth.DefaultChallengeTTL, "How long a login challenge stays valid")
+	sessionTTL := serverCmd.Duration("session-ttl", auth.DefaultSessionTTL, "How long a session token stays valid")
+	nodesFlag := serverCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH to locate registered DIDs on (default: from env NLSS_NODES)")
+
+	serverCmd.Parse(os.Args[2:])
+
+	registry, err := auth.LoadRegistry(*registryPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(registry.Entries) == 0 {
+		fmt.Printf("Error: no DIDs registered in %s\n", *registryPath)
+		os.Exit(1)
+	}
+	for did, entry := range registry.Entries {
+		if entry.PubKey == "" {
+			fmt.Printf("⚠ %s accepts NLSS-only logins (allow_nlss_only); anyone with its DID image and public share can forge them\n", did)
+		}
+	}
+
+	// Entries without image paths are looked up in the DID folder on the node
+	for did, entry := range registry.Entries {
+		if entry.DIDImage == "" || entry.PubShare == "" {
+			cfg := loadDIDConfigOrExit(did, *nodesFlag)
+			didImagePath, pubSharePath, err := cfg.GetNLSSImagePaths(did)
+			if err != nil {
+				fmt.Printf("Error: %s: %v (or set did_image and pub_share in the registry)\n", did, err)
+				os.Exit(1)
+			}
+			if entry.DIDImage == "" {
+				entry.DIDImage = didImagePath
+			}
+			if entry.PubShare == "" {
+				entry.PubShare = pubSharePath
+			}
+		}
+		for _, path := range []string{entry.DIDImage, entry.PubShare, entry.PubKey} {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				fmt.Printf("Error: %s: %v\n", did, err)
+				os.Exit(1)
+			}
+		}
+		registry.Entries[did] = entry
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	server, err := auth.NewServer(registry, []byte(cfg.AuthSessionSecret))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	server.ChallengeTTL = *challengeTTL
+	server.SessionTTL = *sessionTTL
+
+	fmt.Println("DID Login Server:")
+	fmt.Println("=================")
+	fmt.Printf("  Listening: %s\n", *listen)
+	fmt.Printf("  Registered DIDs: %d\n", len(registry.Entries))
+	fmt.Printf("  Challenge TTL: %s\n", *challengeTTL)
+	fmt.Printf("  Session TTL: %s\n", *sessionTTL)
+	if cfg.AuthSessionSecret == "" {
+		fmt.Println("  ⚠ AUTH_SESSION_SECRET not set: using a random secret, sessions end on restart")
+	}
+	fmt.Println("\nEndpoints:")
+	fmt.Println("  POST /auth/challenge  {\"did\": \"...\"}")
+	fmt.Println("  POST /auth/login      document from: break-nlss sign-message --did <did> --message <challenge>")
+	fmt.Println("  GET  /auth/session    Authorization: Bearer <token>")
+
+	if err := http.ListenAndServe(*listen, server.Handler()); err != nil {
+		fmt.Printf("\n❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runAudit() {
+	if len(os.Args) < 3 || os.Args[2] != "verify" {
+		fmt.Println("Usage: break-nlss audit verify [--log audit.jsonl]")
+		os.Exit(1)
+	}
+	verifyCmd := flag.NewFlagSet("audit verify", flag.ExitOnError)
+
+	logPath := verifyCmd.String("log", "", "Audit log to check (default: from env AUDIT_LOG_PATH or audit.jsonl)")
+
+	verifyCmd.Parse(os.Args[3:])
+
+	path := *logPath
+	if path == "" {
+		cfg, err := config.LoadConfigWithOverrides("", "")
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
 		}
+		path = cfg.AuditLogPath
+	}
+	if path == "" {
+		fmt.Println("Error: the audit log is disabled (AUDIT_LOG_PATH=off); pass --log")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Verifying audit log: %s\n", path)
+	report, err := audit.Verify(path)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if report.Entries == 0 {
+		fmt.Println("⚠ The audit log is empty")
+		return
+	}
 
-		// Get output path from config
-		outputPath, err := cfg.GetNLSSOutputPath(did)
+	fmt.Printf("✓ Chain intact: %d entries\n", report.Entries)
+	fmt.Printf("  Last entry: %s\n", report.Head.Time.Local().Format(time.RFC3339))
+	fmt.Printf("  Head hash: %s\n", report.Head.Hash)
+	fmt.Println("  Keep a copy of the head hash elsewhere to detect a rewritten log")
+}
+
+func runSimulateNode() {
+	simCmd := flag.NewFlagSet("simulate-node", flag.ExitOnError)
+
+	listen := simCmd.String("listen", "localhost:20006", "Address to listen on (use as RUBIX_NODE_URL)")
+	accountsPath := simCmd.String("accounts", "", "JSON file of accounts to register")
+	scan := simCmd.Bool("scan", false, "Register every DID found under NLSS_BASE_PATH")
+	balance := simCmd.Float64("balance", 100, "Starting balance of scanned DIDs")
+	didType := simCmd.Int("did-type", rubix.DIDTypeWallet, "DID type of scanned DIDs (0 basic, 1 standard, 2 wallet, 3 child, 4 lite)")
+	nodesFlag := simCmd.String("nodes", "", "Node names or globs under NLSS_BASE_PATH to scan (default: from env NLSS_NODES)")
+	confirmDelay := simCmd.Duration("confirm-delay", 0, "How long accepted transfers stay pending before they are final")
+
+	simCmd.Parse(os.Args[2:])
+
+	if *accountsPath == "" && !*scan {
+		fmt.Println("Error: --accounts or --scan is required")
+		simCmd.Usage()
+		os.Exit(1)
+	}
+
+	var accounts []simnode.Account
+	if *accountsPath != "" {
+		loaded, err := simnode.LoadAccounts(*accountsPath)
 		if err != nil {
-			fmt.Printf("❌ Error constructing output path: %v\n\n", err)
-			failCount++
-			continue
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
+		accounts = append(accounts, loaded...)
+	}
 
-		fmt.Printf("  DID Image: %s\n", didImagePath)
-		fmt.Printf("  Public Share: %s\n", pubSharePath)
-		fmt.Printf("  Output: %s\n", outputPath)
+	if *scan {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		kind, err := rubix.SignerKindForDIDType(*didType)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 
-		// Check if input files exist
-		if _, err := os.Stat(didImagePath); os.IsNotExist(err) {
-			fmt.Printf("❌ Error: DID image file not found: %s\n\n", didImagePath)
-			failCount++
-			continue
+		for _, folder := range discoverDIDFoldersOrExit(cfg, resolveNodesOrExit(cfg, *nodesFlag)) {
+			if !folder.Complete() {
+				continue
+			}
+			account := simnode.Account{
+				DID:      folder.DID,
+				DIDType:  *didType,
+				Balance:  *balance,
+				DIDImage: filepath.Join(folder.Path, cfg.NLSSDIDImageName),
+				PubShare: filepath.Join(folder.Path, cfg.NLSSPubShareName),
+			}
+			if kind != rubix.SignerNLSS {
+				account.PubKeyPath = filepath.Join(folder.Path, cfg.NLSSPubKeyName)
+			}
+			accounts = append(accounts, account)
 		}
+	}
 
-		if _, err := os.Stat(pubSharePath); os.IsNotExist(err) {
-			fmt.Printf("❌ Error: Public share file not found: %s\n\n", pubSharePath)
-			failCount++
-			continue
+	node := simnode.New()
+	node.ConfirmDelay = *confirmDelay
+	for _, account := range accounts {
+		if err := node.AddAccount(account); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
+	}
+	node.Logf = func(format string, args ...any) {
+		fmt.Printf("  %s  %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+	}
+
+	server, err := simnode.Start(node, *listen)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Rubix Node Simulator:")
+	fmt.Println("=====================")
+	fmt.Printf("  Listening: %s\n", server.Addr)
+	fmt.Printf("  Accounts: %d\n", len(accounts))
+	for _, account := range accounts {
+		fmt.Printf("    %s  type %d  %.2f RBT\n", account.DID, account.DIDType, account.Balance)
+	}
+	fmt.Printf("\nPoint break-nlss at it with RUBIX_NODE_URL=%s\n\n", server.Addr)
+
+	// Serve until interrupted
+	select {}
+}
+
+// readMessageOrExit returns the message text or file contents to sign or verify
+func readMessageOrExit(fs *flag.FlagSet, message, file string) []byte {
+	if (message == "") == (file == "") {
+		fmt.Println("Error: exactly one of --message or --file is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if message != "" {
+		return []byte(message)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	return data
+}
+
+// loadDIDConfigOrExit loads the configuration for a DID, tied to its node in multi-node mode
+func loadDIDConfigOrExit(did, nodesSpec string) *config.Config {
+	cfg, err := locateDID(loadDIDConfigOnlyOrExit(did), nodesSpec)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// loadDIDConfigOnlyOrExit loads the configuration for a DID without looking
+// for its node
+func loadDIDConfigOnlyOrExit(did string) *config.Config {
+	cfg, err := config.LoadConfigWithOverrides("", did)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
 
-		// Run the BreakNLSS algorithm
-		err = nlss.BreakNLSSFromFiles(didImagePath, pubSharePath, outputPath)
+// locateDID points cfg at the node holding its DID when nodes are configured.
+// On error, cfg is returned unchanged.
+func locateDID(cfg *config.Config, nodesSpec string) (*config.Config, error) {
+	if nodes := resolveNodesOrExit(cfg, nodesSpec); len(nodes) > 0 && cfg.SenderDID != "" {
+		node, err := cfg.FindDIDNode(cfg.SenderDID, nodes)
 		if err != nil {
-			fmt.Printf("❌ Error: %v\n\n", err)
-			failCount++
-			continue
+			return cfg, err
+		}
+		cfg = cfg.ForNode(node)
+	}
+	return cfg, nil
+}
+
+// openLedgerOrExit opens the local transfer ledger, or returns nil if LEDGER_PATH=off
+func openLedgerOrExit(cfg *config.Config) *storage.Ledger {
+	if cfg.LedgerPath == "" {
+		return nil
+	}
+	ledger, err := storage.OpenLedger(cfg.LedgerPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return ledger
+}
+
+// recordTransfer appends the transfer's current state to the ledger. A failed
+// write is reported and returned, so the caller can stop before the next phase.
+func recordTransfer(ledger *storage.Ledger, r *rubix.TransferResult) error {
+	if ledger == nil {
+		return nil
+	}
+	if err := ledger.Append(ledgerEntry(r)); err != nil {
+		fmt.Printf("⚠ Failed to record transfer %s in the ledger: %v\n", r.RequestID, err)
+		return err
+	}
+	return nil
+}
+
+func ledgerEntry(r *rubix.TransferResult) storage.LedgerEntry {
+	e := storage.LedgerEntry{
+		ID:             r.ID,
+		IdempotencyKey: r.IdempotencyKey,
+		RequestID:      r.RequestID,
+		TransactionID:  r.TransactionID,
+		SenderDID:      r.SenderDID,
+		ReceiverDID:    r.ReceiverDID,
+		Amount:         r.Amount,
+		Comment:        r.Comment,
+		Hash:           r.Hash,
+		NodeURL:        r.NodeURL,
+		State:          r.State,
+		Message:        r.Message,
+		Error:          r.Error,
+		CreatedAt:      r.CreatedAt,
+	}
+	if r.Signature != nil {
+		e.Signature, e.Pixels = r.Signature.Signature, r.Signature.Pixels
+	}
+	return e
+}
+
+func transferResultFromEntry(e storage.LedgerEntry) *rubix.TransferResult {
+	r := &rubix.TransferResult{
+		ID:             e.ID,
+		IdempotencyKey: e.IdempotencyKey,
+		RequestID:      e.RequestID,
+		TransactionID:  e.TransactionID,
+		NodeURL:        e.NodeURL,
+		SenderDID:      e.SenderDID,
+		ReceiverDID:    e.ReceiverDID,
+		Amount:         e.Amount,
+		Comment:        e.Comment,
+		Hash:           e.Hash,
+		State:          e.State,
+		Message:        e.Message,
+		Error:          e.Error,
+		CreatedAt:      e.CreatedAt,
+		UpdatedAt:      e.Timestamp,
+	}
+	if len(e.Signature) > 0 || len(e.Pixels) > 0 {
+		r.Signature = &rubix.SignatureData{Signature: e.Signature, Pixels: e.Pixels}
+	}
+	return r
+}
+
+// findReceiptOrExit returns the ledger receipt of a transfer by local or
+// request ID, or by transaction ID
+func findReceiptOrExit(cfg *config.Config, id, txnID string) (storage.LedgerEntry, bool) {
+	for _, r := range loadReceiptsOrExit(cfg) {
+		if (id != "" && (r.ID == id || r.RequestID == id)) || (txnID != "" && r.TransactionID == txnID) {
+			return r, true
 		}
+	}
+	return storage.LedgerEntry{}, false
+}
 
-		fmt.Printf("✓ Successfully reconstructed private share!\n")
-		fmt.Printf("  Saved to: %s\n\n", outputPath)
-		successCount++
+func loadReceiptsOrExit(cfg *config.Config) []storage.LedgerEntry {
+	entries, err := storage.LoadLedger(cfg.LedgerPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
+	return storage.Receipts(entries)
+}
 
-	// Print summary
-	fmt.Println("============================================")
-	fmt.Println("Summary:")
-	fmt.Printf("  Total DIDs: %d\n", len(dids))
-	fmt.Printf("  Successful: %d\n", successCount)
-	fmt.Printf("  Failed: %d\n", failCount)
-	fmt.Println("\nIMPORTANT: Keep your private shares secure and never share them!")
+// duplicateWindow is how long an identical transfer is refused without --allow-duplicate
+const duplicateWindow = 24 * time.Hour
+
+// duplicateGuardFlags keep a transfer from being initiated twice by accident
+type duplicateGuardFlags struct {
+	key            *string
+	allowDuplicate *bool
+}
+
+func addDuplicateGuardFlags(fs *flag.FlagSet) *duplicateGuardFlags {
+	return &duplicateGuardFlags{
+		key:            fs.String("idempotency-key", "", "Key identifying this transfer; one made with the same key is never repeated (default: derived from sender, receiver, amount and comment, for 24h)"),
+		allowDuplicate: fs.Bool("allow-duplicate", false, "Initiate the transfer even if an identical one was made or is unfinished"),
+	}
+}
+
+// checkOrExit lists the sender's unfinished transfers from the ledger and
+// refuses an identical transfer unless --allow-duplicate is set. It returns
+// the transfer's idempotency key.
+func (f *duplicateGuardFlags) checkOrExit(cfg *config.Config, receiver string, amount float64, comment string) string {
+	key := *f.key
+	since := time.Time{}
+	if key == "" {
+		key = rubix.TransferKey(cfg.SenderDID, receiver, amount, comment)
+		since = time.Now().Add(-duplicateWindow)
+	}
+	if cfg.LedgerPath == "" {
+		fmt.Println("⚠ The ledger is disabled (LEDGER_PATH=off): duplicate transfers are not detected")
+		return key
+	}
+
+	receipts := loadReceiptsOrExit(cfg)
+	var unfinished []storage.LedgerEntry
+	for _, r := range receipts {
+		if r.SenderDID == cfg.SenderDID && r.Unfinished() {
+			unfinished = append(unfinished, r)
+		}
+	}
+	if len(unfinished) > 0 {
+		fmt.Printf("⚠ %d unfinished transfers of %s in %s:\n", len(unfinished), cfg.SenderDID, cfg.LedgerPath)
+		printUnfinishedTransfers(unfinished)
+		fmt.Println()
+	}
+
+	dups := storage.FindDuplicates(receipts, key, since)
+	if len(dups) == 0 {
+		return key
+	}
+	dup := dups[len(dups)-1]
+	if *f.allowDuplicate {
+		fmt.Printf("⚠ Initiating a duplicate of transfer %s (%s, %s)\n", dup.TransferID(), dup.State, dup.CreatedAt.Local().Format(time.RFC3339))
+		return key
+	}
+	fmt.Printf("❌ An identical transfer was already made: %s (%s, %s)\n", dup.TransferID(), dup.State, dup.CreatedAt.Local().Format(time.RFC3339))
+	if dup.Unfinished() {
+		fmt.Printf("  Finish it with: break-nlss transfer recover --id %s --resume (or --abandon)\n", dup.TransferID())
+	}
+	fmt.Println("  Pass --allow-duplicate to send it again")
+	os.Exit(exitDuplicateTransfer)
+	return ""
+}
+
+func printUnfinishedTransfers(receipts []storage.LedgerEntry) {
+	for _, r := range receipts {
+		fmt.Printf("  %s  %-10s  %.3f RBT to %s  (%s)\n",
+			r.TransferID(), r.State, r.Amount, r.ReceiverDID, r.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Println("  Resume or abandon with: break-nlss transfer recover --id <id> --resume|--abandon")
+}
+
+// newRubixClientOrExit creates a node client with the configured timeout,
+// retries, TLS settings and auth headers
+func newRubixClientOrExit(cfg *config.Config, url string) *rubix.Client {
+	client, err := rubix.NewClientWithOptions(url, rubix.ClientOptions{
+		CAFile:       cfg.RubixCAFile,
+		CertFile:     cfg.RubixClientCert,
+		KeyFile:      cfg.RubixClientKey,
+		APIKey:       cfg.RubixAPIKey,
+		APIKeyHeader: cfg.RubixAPIKeyHeader,
+		BearerToken:  cfg.RubixBearerToken,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	client.Timeout = cfg.RubixTimeout
+	client.Retry.MaxRetries = cfg.RubixRetries
+	return client
+}
+
+// openAuditLog opens the signing audit log, or returns nil if AUDIT_LOG_PATH=off
+func openAuditLog(cfg *config.Config) (*audit.Log, error) {
+	if cfg.AuditLogPath == "" {
+		return nil, nil
+	}
+	return audit.Open(cfg.AuditLogPath)
+}
+
+func openAuditLogOrExit(cfg *config.Config) *audit.Log {
+	auditLog, err := openAuditLog(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return auditLog
+}
+
+// auditCommand names the running command for audit entries, e.g. "transfer sign"
+func auditCommand() string {
+	if len(os.Args) > 2 && !strings.HasPrefix(os.Args[2], "-") {
+		return os.Args[1] + " " + os.Args[2]
+	}
+	return os.Args[1]
+}
+
+// openVaultOrExit opens the configured private share vault, or returns nil if none is configured
+func openVaultOrExit(cfg *config.Config) *vault.Vault {
+	v, err := cfg.OpenVault()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return v
+}
+
+// resolveNodesOrExit expands the --nodes flag (or NLSS_NODES) into node names.
+// It returns nil when neither is set, meaning single-node mode.
+func resolveNodesOrExit(cfg *config.Config, spec string) []string {
+	if spec == "" {
+		spec = cfg.NLSSNodes
+	}
+	if spec == "" {
+		return nil
+	}
+
+	nodes, err := cfg.ResolveNodes(spec)
+	if err != nil {
+		fmt.Printf("Error resolving nodes: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Nodes: %s\n", strings.Join(nodes, ", "))
+	return nodes
+}
+
+// discoverDIDFoldersOrExit discovers DID folders on the configured node, or on
+// every node in nodes when multi-node mode is active
+func discoverDIDFoldersOrExit(cfg *config.Config, nodes []string) []config.DIDFolder {
+	if len(nodes) == 0 {
+		folders, err := cfg.DiscoverDIDs()
+		if err != nil {
+			fmt.Printf("Error discovering DIDs: %v\n", err)
+			os.Exit(1)
+		}
+		return folders
+	}
+
+	var folders []config.DIDFolder
+	for _, node := range nodes {
+		nodeFolders, err := cfg.ForNode(node).DiscoverDIDs()
+		if err != nil {
+			fmt.Printf("Error discovering DIDs on node %s: %v\n", node, err)
+			os.Exit(1)
+		}
+		folders = append(folders, nodeFolders...)
+	}
+	return folders
 }
 
 // readDIDsFromFile reads DIDs from a text file (one per line)
+// uniqueDIDs drops repeated DIDs, keeping the first of each: every DID is
+// written to one output path, so it must be processed once
+func uniqueDIDs(dids []string) []string {
+	seen := make(map[string]bool, len(dids))
+	var unique []string
+	for _, did := range dids {
+		if seen[did] {
+			fmt.Printf("⚠ Skipping repeated DID %s\n", did)
+			continue
+		}
+		seen[did] = true
+		unique = append(unique, did)
+	}
+	return unique
+}
+
 func readDIDsFromFile(filepath string) ([]string, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -0,0 +1,262 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"break-nlss/pkg/crypto"
+)
+
+// Audited operations
+const (
+	OpSign        = "sign"        // a private share or ECDSA key signed a hash
+	OpReconstruct = "reconstruct" // a private share was rebuilt from the DID image and public share
+)
+
+// ErrTampered is returned by Verify when entries were edited, reordered or deleted
+var ErrTampered = errors.New("audit log tampered")
+
+// Entry is one line of the audit log. Prev chains it to the entry before, so
+// editing or deleting any entry breaks the chain at the next one.
+type Entry struct {
+	Seq       int       `json:"seq"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Command   string    `json:"command,omitempty"` // CLI command, e.g. "transfer sign"
+	DID       string    `json:"did,omitempty"`
+	Signer    string    `json:"signer,omitempty"`  // nlss, ecdsa or nlss+ecdsa
+	Source    string    `json:"source,omitempty"`  // share or key used, or the inputs of a reconstruction
+	Output    string    `json:"output,omitempty"`  // where a reconstructed share was written
+	Subject   string    `json:"subject,omitempty"` // hash that was signed
+	Digest    string    `json:"digest,omitempty"`  // SHA3-256 of the signature produced (pixels, then ECDSA bytes)
+	Error     string    `json:"error,omitempty"`
+	Prev      string    `json:"prev"` // SHA3-256 of the previous entry's line; empty for the first
+}
+
+// Head anchors the end of the log: the sequence number and hash of the last
+// entry. It is what reveals entries deleted from the end of the log, and can
+// be copied elsewhere to pin the log's state.
+type Head struct {
+	Seq  int       `json:"seq"`
+	Hash string    `json:"hash"`
+	Time time.Time `json:"time"`
+}
+
+// HeadPath returns the path of the head anchor of the log at path
+func HeadPath(path string) string {
+	return path + ".head"
+}
+
+// Log appends entries to a JSON lines audit log. Every entry is synced to disk
+// and the head anchor updated before Append returns. Appends hold an exclusive
+// lock on the log file, so several processes can share one log.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Open prepares the log at path for appending, continuing its chain. The file
+// is created on the first Append.
+func Open(path string) (*Log, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+	}
+	if _, err := loadHead(path); err != nil {
+		return nil, err
+	}
+	return &Log{path: path}, nil
+}
+
+// loadHead returns the end of the chain: the head anchor or, when there is
+// none yet, the last entry
+func loadHead(path string) (Head, error) {
+	head, err := readHead(path)
+	if err != nil {
+		return Head{}, err
+	}
+	if head != nil {
+		return *head, nil
+	}
+
+	lines, err := readLines(path)
+	if err != nil {
+		return Head{}, err
+	}
+	n := len(lines)
+	if n == 0 {
+		return Head{}, nil
+	}
+	var last Entry
+	if err := json.Unmarshal(lines[n-1], &last); err != nil {
+		return Head{}, fmt.Errorf("invalid last audit entry: %w", err)
+	}
+	return Head{Seq: last.Seq, Hash: lineHash(lines[n-1]), Time: last.Time}, nil
+}
+
+// Path returns the log file's path
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append fills in the entry's sequence number, time and chain hash and writes
+// it. The chain is continued from the head as it is on disk once the lock is
+// held, so entries appended by other processes are chained in.
+func (l *Log) Append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer unlockFile(file)
+
+	head, err := loadHead(l.path)
+	if err != nil {
+		return err
+	}
+	e.Seq = head.Seq + 1
+	e.Prev = head.Hash
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return writeHead(l.path, Head{Seq: e.Seq, Hash: lineHash(line), Time: e.Time})
+}
+
+// Report summarizes a verified log
+type Report struct {
+	Entries int
+	Head    Head
+}
+
+// Verify checks the chain of the log at path and that it ends at its head
+// anchor. Any edit, reordering or deletion of entries is reported as an error
+// matching ErrTampered that names the first entry affected.
+func Verify(path string) (*Report, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	head, err := readHead(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	prev := ""
+	for i, line := range lines {
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("%w: line %d is not a valid entry: %v", ErrTampered, i+1, err)
+		}
+		if e.Seq != i+1 {
+			return nil, fmt.Errorf("%w: line %d holds entry %d (entries deleted or reordered)", ErrTampered, i+1, e.Seq)
+		}
+		if e.Prev != prev {
+			return nil, fmt.Errorf("%w: chain broken at entry %d (it or the entry before it was edited)", ErrTampered, e.Seq)
+		}
+		prev = lineHash(line)
+		report.Head = Head{Seq: e.Seq, Hash: prev, Time: e.Time}
+	}
+	report.Entries = len(lines)
+
+	switch {
+	case head == nil && len(lines) > 0:
+		return nil, fmt.Errorf("%w: head anchor %s is missing", ErrTampered, HeadPath(path))
+	case head == nil:
+		return report, nil
+	case head.Seq != report.Head.Seq:
+		return nil, fmt.Errorf("%w: log ends at entry %d but its head is entry %d (entries deleted from the end)", ErrTampered, report.Head.Seq, head.Seq)
+	case head.Hash != report.Head.Hash:
+		return nil, fmt.Errorf("%w: entry %d does not match the head anchor (last entry edited)", ErrTampered, head.Seq)
+	}
+	return report, nil
+}
+
+// lineHash is the chain hash of one entry: SHA3-256 of its JSON line
+func lineHash(line []byte) string {
+	return hex.EncodeToString(crypto.CalculateSHA3HashBytes(line))
+}
+
+// readLines returns the non-empty lines of the log; a missing log has none
+func readLines(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, bytes.Clone(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading audit log: %w", err)
+	}
+	return lines, nil
+}
+
+// readHead reads the head anchor; nil when there is none
+func readHead(path string) (*Head, error) {
+	data, err := os.ReadFile(HeadPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit head: %w", err)
+	}
+	var head Head
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("%w: invalid head anchor %s: %v", ErrTampered, HeadPath(path), err)
+	}
+	return &head, nil
+}
+
+// writeHead replaces the head anchor atomically
+func writeHead(path string, head Head) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit head: %w", err)
+	}
+	tmp := HeadPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write audit head: %w", err)
+	}
+	if err := os.Rename(tmp, HeadPath(path)); err != nil {
+		return fmt.Errorf("failed to write audit head: %w", err)
+	}
+	return nil
+}
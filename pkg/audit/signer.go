@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/rubix"
+)
+
+// Signer records every signature of the signer it wraps in the audit log.
+// Entry holds the fields common to all its entries (command, DID, signer kind
+// and source); Sign adds the hash and the signature's digest. A signature that
+// cannot be recorded is not returned.
+type Signer struct {
+	Signer rubix.Signer
+	Log    *Log
+	Entry  Entry
+}
+
+// Sign signs with the wrapped signer and records the outcome
+func (s *Signer) Sign(hash string) (*rubix.SignatureData, error) {
+	sig, err := s.Signer.Sign(hash)
+
+	e := s.Entry
+	e.Operation, e.Subject = OpSign, hash
+	if e.Source == "" {
+		e.Source = rubix.ShareSource(s.Signer)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	} else {
+		e.Digest = SignatureDigest(sig)
+	}
+	if logErr := s.Log.Append(e); logErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("signature not recorded in audit log: %w", logErr)
+	}
+	return sig, err
+}
+
+// Unwrap returns the wrapped signer
+func (s *Signer) Unwrap() rubix.Signer {
+	return s.Signer
+}
+
+// Close wipes the secret material of the wrapped signer
+func (s *Signer) Close() error {
+	return rubix.CloseSigner(s.Signer)
+}
+
+// SignatureDigest identifies a signature in the log without storing it: the
+// hex SHA3-256 of its pixels followed by its ECDSA bytes
+func SignatureDigest(sig *rubix.SignatureData) string {
+	data := append(append([]byte{}, sig.Pixels...), sig.Signature...)
+	return hex.EncodeToString(crypto.CalculateSHA3HashBytes(data))
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+// SignedMessageVersion is the current signature document format
+const SignedMessageVersion = "1"
+
+// ErrInvalidSignature is returned when a signed message fails verification
+var ErrInvalidSignature = errors.New("invalid message signature")
+
+// SignedMessage is a portable document proving that the holder of a DID's
+// private share signed a message. Hash is the SHA3-256 of the message, signed
+// like a transaction hash: Pixels with the private share and, for nlss+ecdsa,
+// Signature with the DID's key over the Pixels digest.
+type SignedMessage struct {
+	Version   string    `json:"version"`
+	DID       string    `json:"did"`
+	Signer    string    `json:"signer"` // nlss or nlss+ecdsa
+	Hash      string    `json:"hash"`
+	Message   string    `json:"message,omitempty"` // embedded message, if any
+	Pixels    []byte    `json:"pixels"`
+	Signature []byte    `json:"signature,omitempty"`
+	SignedAt  time.Time `json:"signed_at"`
+}
+
+// HashMessage returns the SHA3-256 hash of a message as signed by SignMessage
+func HashMessage(data []byte) string {
+	return crypto.CalculateSHA3Hash(string(data))
+}
+
+// SignMessage signs a message for a DID. Only image signers (nlss, nlss+ecdsa)
+// are accepted, since verification relies on the DID image and public share.
+// With embed set, the message itself is included in the document.
+func SignMessage(did string, data []byte, signer rubix.Signer, embed bool) (*SignedMessage, error) {
+	hash := HashMessage(data)
+	sig, err := signer.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig.Pixels) == 0 {
+		return nil, fmt.Errorf("messages must be signed with the private share (use %s or %s)", rubix.SignerNLSS, rubix.SignerCombined)
+	}
+
+	kind := rubix.SignerNLSS
+	if len(sig.Signature) > 0 {
+		kind = rubix.SignerCombined
+	}
+
+	m := &SignedMessage{
+		Version:   SignedMessageVersion,
+		DID:       did,
+		Signer:    kind,
+		Hash:      hash,
+		Pixels:    sig.Pixels,
+		Signature: sig.Signature,
+		SignedAt:  time.Now(),
+	}
+	if embed {
+		m.Message = string(data)
+	}
+	return m, nil
+}
+
+// CheckMessage verifies that data is the message the document was signed for
+func (m *SignedMessage) CheckMessage(data []byte) error {
+	if HashMessage(data) != m.Hash {
+		return fmt.Errorf("%w: message does not match the signed hash", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// Verify checks the signatures against the DID image and public share and,
+// when pubKey is given, the ECDSA signature against it. A given pubKey always
+// requires the ECDSA signature, whatever the document's Signer claims; it may
+// be nil only for nlss documents.
+func (m *SignedMessage) Verify(didImagePath, pubSharePath string, pubKey *ecdsa.PublicKey) error {
+	if m.Version != SignedMessageVersion {
+		return fmt.Errorf("unsupported signature document version %q", m.Version)
+	}
+	if m.Signer != rubix.SignerNLSS && m.Signer != rubix.SignerCombined {
+		return fmt.Errorf("%w: unsupported signer %q", ErrInvalidSignature, m.Signer)
+	}
+	if m.Message != "" {
+		if err := m.CheckMessage([]byte(m.Message)); err != nil {
+			return err
+		}
+	}
+
+	ok, err := nlss.NlssVerify(didImagePath, pubSharePath, m.Hash, m.Pixels)
+	if err != nil || !ok {
+		return fmt.Errorf("%w: image signature does not match %s (%v)", ErrInvalidSignature, m.DID, err)
+	}
+
+	if pubKey == nil {
+		if m.Signer == rubix.SignerCombined {
+			return fmt.Errorf("document carries an ECDSA signature but no public key was given")
+		}
+		return nil
+	}
+	if len(m.Signature) == 0 {
+		return fmt.Errorf("%w: ECDSA signature missing", ErrInvalidSignature)
+	}
+	ok, err = crypto.VerifyECDSASignature(pubKey, []byte(rubix.PixelsDigest(m.Pixels)), m.Signature)
+	if err != nil || !ok {
+		return fmt.Errorf("%w: ECDSA signature does not match the public key", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// SaveSignedMessage writes a signature document as JSON
+func SaveSignedMessage(path string, m *SignedMessage) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature document: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write signature document: %w", err)
+	}
+	return nil
+}
+
+// LoadSignedMessage reads a signature document
+func LoadSignedMessage(path string) (*SignedMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature document: %w", err)
+	}
+
+	var m SignedMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse signature document: %w", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,398 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"break-nlss/pkg/crypto"
+)
+
+// Default lifetimes of challenges and session tokens
+const (
+	DefaultChallengeTTL = 2 * time.Minute
+	DefaultSessionTTL   = 15 * time.Minute
+)
+
+// MaxChallengesPerDID bounds the outstanding challenges of one DID; issuing
+// another drops the oldest. Only registered DIDs get challenges, so this also
+// bounds the memory the unauthenticated challenge endpoint can take.
+const MaxChallengesPerDID = 5
+
+// Request body limits of the unauthenticated endpoints. A login document
+// holds a short challenge and signatures of a few hundred bytes.
+const (
+	maxChallengeBody = 4 << 10
+	maxLoginBody     = 16 << 10
+)
+
+// ErrInvalidToken is returned for malformed, forged or expired session tokens
+var ErrInvalidToken = errors.New("invalid session token")
+
+// RegistryEntry is a DID allowed to log in, with the public material to verify it.
+// Relative paths are resolved against the registry file's directory.
+type RegistryEntry struct {
+	DID      string `json:"did"`
+	DIDImage string `json:"did_image"`
+	PubShare string `json:"pub_share"`
+	PubKey   string `json:"pub_key,omitempty"` // when set, logins must carry a matching ECDSA signature
+
+	// AllowNLSSOnly accepts logins with the image signature alone when there is
+	// no PubKey. Such logins can be forged from the DID's public images.
+	AllowNLSSOnly bool `json:"allow_nlss_only,omitempty"`
+}
+
+// Registry holds the DIDs allowed to log in
+type Registry struct {
+	Entries map[string]RegistryEntry
+}
+
+// registryFile is the on-disk registry format
+type registryFile struct {
+	DIDs []RegistryEntry `json:"dids"`
+}
+
+// LoadRegistry reads a registry file. Every entry needs a pub_key unless it
+// explicitly sets allow_nlss_only.
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry: %w", err)
+	}
+
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse registry: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	r := &Registry{Entries: make(map[string]RegistryEntry, len(file.DIDs))}
+	for _, e := range file.DIDs {
+		if e.DID == "" {
+			return nil, fmt.Errorf("registry entry without a DID")
+		}
+		if e.PubKey == "" && !e.AllowNLSSOnly {
+			return nil, fmt.Errorf("registry entry %s has no pub_key (set \"allow_nlss_only\": true to accept forgeable NLSS-only logins)", e.DID)
+		}
+		e.DIDImage, e.PubShare, e.PubKey = resolve(e.DIDImage), resolve(e.PubShare), resolve(e.PubKey)
+		r.Entries[e.DID] = e
+	}
+	return r, nil
+}
+
+// Session is an authenticated DID carried by a session token
+type Session struct {
+	DID       string    `json:"did"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// challenge is an outstanding login challenge for a DID
+type challenge struct {
+	did       string
+	expiresAt time.Time
+}
+
+// Server authenticates DID holders with NLSS challenge-response logins:
+//
+//	POST /auth/challenge  {"did": "..."}           -> {"challenge": "...", "expires_at": "..."}
+//	POST /auth/login      sign-message document    -> {"token": "...", "did": "...", "expires_at": "..."}
+//	GET  /auth/session    Authorization: Bearer ... -> {"did": "...", "issued_at": "...", "expires_at": "..."}
+//
+// The login document is what `sign-message --message <challenge>` writes.
+// Session tokens are HMAC-SHA256 signed and expire after SessionTTL.
+type Server struct {
+	Registry     *Registry
+	ChallengeTTL time.Duration
+	SessionTTL   time.Duration
+
+	secret []byte
+	now    func() time.Time
+
+	mu         sync.Mutex
+	challenges map[string]challenge
+}
+
+// NewServer creates a login server. secret signs session tokens; if empty, a
+// random secret is used and tokens do not survive a restart.
+func NewServer(registry *Registry, secret []byte) (*Server, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate session secret: %w", err)
+		}
+	}
+
+	return &Server{
+		Registry:     registry,
+		ChallengeTTL: DefaultChallengeTTL,
+		SessionTTL:   DefaultSessionTTL,
+		secret:       secret,
+		now:          time.Now,
+		challenges:   make(map[string]challenge),
+	}, nil
+}
+
+// SetClock replaces the server's time source (for tests)
+func (s *Server) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Handler returns the HTTP handler serving the /auth endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/challenge", s.handleChallenge)
+	mux.HandleFunc("POST /auth/login", s.handleLogin)
+	mux.HandleFunc("GET /auth/session", s.handleSession)
+	return mux
+}
+
+// NewChallenge issues a single-use challenge for a registered DID. A DID has
+// at most MaxChallengesPerDID outstanding; the oldest is dropped first.
+func (s *Server) NewChallenge(did string) (string, time.Time, error) {
+	if _, ok := s.Registry.Entries[did]; !ok {
+		return "", time.Time{}, fmt.Errorf("DID not registered: %s", did)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	value := "break-nlss-login:" + hex.EncodeToString(buf)
+	expiresAt := s.now().Add(s.ChallengeTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneChallenges()
+	s.dropOldestChallenge(did)
+	s.challenges[value] = challenge{did: did, expiresAt: expiresAt}
+	return value, expiresAt, nil
+}
+
+// dropOldestChallenge makes room for one more challenge for did; the caller
+// holds s.mu
+func (s *Server) dropOldestChallenge(did string) {
+	count, oldest := 0, ""
+	for value, c := range s.challenges {
+		if c.did != did {
+			continue
+		}
+		count++
+		if oldest == "" || c.expiresAt.Before(s.challenges[oldest].expiresAt) {
+			oldest = value
+		}
+	}
+	if count >= MaxChallengesPerDID {
+		delete(s.challenges, oldest)
+	}
+}
+
+// pruneChallenges drops expired challenges; the caller holds s.mu
+func (s *Server) pruneChallenges() {
+	now := s.now()
+	for value, c := range s.challenges {
+		if now.After(c.expiresAt) {
+			delete(s.challenges, value)
+		}
+	}
+}
+
+// Login verifies a signed challenge and returns a session token. Once
+// presented for the DID it was issued to, the challenge is consumed whether or
+// not the signature verifies. Whether an ECDSA signature is required is
+// decided by the registry entry, not by the document.
+func (s *Server) Login(m *SignedMessage) (string, *Session, error) {
+	s.mu.Lock()
+	c, ok := s.challenges[m.Message]
+	if ok && c.did == m.DID {
+		delete(s.challenges, m.Message)
+	}
+	s.mu.Unlock()
+
+	if !ok || s.now().After(c.expiresAt) {
+		return "", nil, fmt.Errorf("unknown or expired challenge")
+	}
+	if c.did != m.DID {
+		return "", nil, fmt.Errorf("challenge was issued to another DID")
+	}
+
+	entry, ok := s.Registry.Entries[m.DID]
+	if !ok {
+		return "", nil, fmt.Errorf("DID not registered: %s", m.DID)
+	}
+
+	var pubKey *ecdsa.PublicKey
+	if entry.PubKey != "" {
+		var err error
+		pubKey, err = crypto.LoadPublicKeyFromPEM(entry.PubKey)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if err := m.Verify(entry.DIDImage, entry.PubShare, pubKey); err != nil {
+		return "", nil, err
+	}
+
+	now := s.now()
+	session := &Session{DID: m.DID, IssuedAt: now, ExpiresAt: now.Add(s.SessionTTL)}
+	token, err := s.signToken(session)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// signToken encodes a session as base64url(payload) "." base64url(HMAC-SHA256)
+func (s *Server) signToken(session *Session) (string, error) {
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyToken checks a session token's signature and expiry
+func (s *Server) VerifyToken(token string) (*Session, error) {
+	enc := base64.RawURLEncoding
+	payloadPart, macPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	payload, err := enc.DecodeString(payloadPart)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	sum, err := enc.DecodeString(macPart)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+
+	var session Session
+	if err := json.Unmarshal(payload, &session); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if s.now().After(session.ExpiresAt) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	return &session, nil
+}
+
+type sessionContextKey struct{}
+
+// RequireSession wraps a handler so it only runs with a valid bearer token;
+// the session is available through SessionFromContext
+func (s *Server) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.sessionFromRequest(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session)))
+	})
+}
+
+// SessionFromContext returns the session set by RequireSession
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(*Session)
+	return session, ok
+}
+
+func (s *Server) sessionFromRequest(r *http.Request) (*Session, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing bearer token", ErrInvalidToken)
+	}
+	return s.VerifyToken(token)
+}
+
+func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DID string `json:"did"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxChallengeBody)).Decode(&req); err != nil {
+		writeError(w, bodyErrorStatus(err), fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	value, expiresAt, err := s.NewChallenge(req.DID)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"challenge": value, "expires_at": expiresAt})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var m SignedMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLoginBody)).Decode(&m); err != nil {
+		writeError(w, bodyErrorStatus(err), fmt.Errorf("invalid signature document: %w", err))
+		return
+	}
+
+	token, session, err := s.Login(&m)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"token": token, "did": session.DID, "expires_at": session.ExpiresAt})
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	session, err := s.sessionFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// bodyErrorStatus is the status for a request body that could not be decoded
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
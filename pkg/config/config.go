@@ -4,19 +4,58 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
+
+	"break-nlss/pkg/vault"
 )
 
 // Config holds the application configuration
 type Config struct {
-	RubixNodeURL string // e.g., "localhost:20006"
+	RubixNodeURL string // e.g., "localhost:20006"; a comma-separated list for failover
 	SenderDID    string // e.g., "DID012"
 
+	// RubixNodeURLs maps NLSS node names to their Rubix node URL,
+	// e.g. {"bulk011": "localhost:20006"}; unmapped nodes use RubixNodeURL
+	RubixNodeURLs map[string]string
+
+	RubixTimeout time.Duration // per-call timeout of node requests (default 30s)
+	RubixRetries int           // retries of failed node requests (default 3)
+
+	// TLS and authentication for nodes behind HTTPS proxies or API gateways
+	RubixCAFile       string // extra CA bundle (PEM)
+	RubixClientCert   string // client certificate (PEM) for mutual TLS
+	RubixClientKey    string // client key (PEM) for mutual TLS
+	RubixAPIKey       string // sent in RubixAPIKeyHeader
+	RubixAPIKeyHeader string // e.g. "X-API-Key" (default)
+	RubixBearerToken  string // sent as "Authorization: Bearer <token>"
+
 	// NLSS Configuration
 	NLSSBasePath     string // e.g., "/mnt/storage/bulkset/set1"
 	NLSSNodeName     string // e.g., "bulk011"
+	NLSSNodes        string // e.g., "bulk0*" or "bulk011,bulk012" (multi-node mode)
 	NLSSDIDImageName string // e.g., "did.png" (default)
 	NLSSPubShareName string // e.g., "pubShare.png" (default)
+	NLSSPvtKeyName   string // e.g., "pvtKey.pem" (default)
+	NLSSPubKeyName   string // e.g., "pubKey.pem" (default)
 	NLSSOutputDir    string // e.g., "./output" (default)
+
+	// Vault Configuration (private shares encrypted at rest)
+	NLSSVaultDir      string // e.g., "./vault" (default: NLSSOutputDir)
+	NLSSVaultPassword string // enables the vault when set
+	NLSSVaultKeyFile  string // file holding the vault password, alternative to NLSSVaultPassword
+
+	// Password of encrypted EC private key PEM files used for ecdsa signing
+	PrivateKeyPassword string
+
+	// Secret signing auth-server session tokens (random per run when empty)
+	AuthSessionSecret string
+
+	// Local transfer ledger (JSON lines); empty when disabled with LEDGER_PATH=off
+	LedgerPath string
+
+	// Hash-chained log of signing operations; empty when disabled with AUDIT_LOG_PATH=off
+	AuditLogPath string
 }
 
 // LoadConfig loads configuration from environment variables with defaults
@@ -38,25 +77,103 @@ func LoadConfig() (*Config, error) {
 	if nlssPubShareName == "" {
 		nlssPubShareName = "pubShare.png"
 	}
+	nlssPvtKeyName := os.Getenv("NLSS_PVT_KEY_NAME")
+	if nlssPvtKeyName == "" {
+		nlssPvtKeyName = "pvtKey.pem"
+	}
+	nlssPubKeyName := os.Getenv("NLSS_PUB_KEY_NAME")
+	if nlssPubKeyName == "" {
+		nlssPubKeyName = "pubKey.pem"
+	}
 	nlssOutputDir := os.Getenv("NLSS_OUTPUT_DIR")
 	if nlssOutputDir == "" {
 		cwd, _ := os.Getwd()
 		nlssOutputDir = filepath.Join(cwd, "output")
 	}
 
+	rubixNodeURLs, err := parseNodeURLs(os.Getenv("RUBIX_NODE_URLS"))
+	if err != nil {
+		return nil, err
+	}
+
+	ledgerPath := os.Getenv("LEDGER_PATH")
+	switch ledgerPath {
+	case "":
+		ledgerPath = defaultLedgerPath(nlssOutputDir)
+	case "off":
+		ledgerPath = ""
+	}
+
+	auditLogPath := os.Getenv("AUDIT_LOG_PATH")
+	switch auditLogPath {
+	case "":
+		auditLogPath = "audit.jsonl"
+	case "off":
+		auditLogPath = ""
+	}
+
+	rubixTimeout := 30 * time.Second
+	if value := os.Getenv("RUBIX_TIMEOUT"); value != "" {
+		rubixTimeout, err = time.ParseDuration(value)
+		if err != nil || rubixTimeout < 0 {
+			return nil, fmt.Errorf("invalid RUBIX_TIMEOUT %q (expected a duration like 30s)", value)
+		}
+	}
+	rubixRetries := 3
+	if value := os.Getenv("RUBIX_RETRIES"); value != "" {
+		rubixRetries, err = strconv.Atoi(value)
+		if err != nil || rubixRetries < 0 {
+			return nil, fmt.Errorf("invalid RUBIX_RETRIES %q (expected a non-negative number)", value)
+		}
+	}
+
 	config := &Config{
-		RubixNodeURL:     rubixNodeURL,
-		SenderDID:        os.Getenv("SENDER_DID"),
-		NLSSBasePath:     nlssBasePath,
-		NLSSNodeName:     nlssNodeName,
-		NLSSDIDImageName: nlssDIDImageName,
-		NLSSPubShareName: nlssPubShareName,
-		NLSSOutputDir:    nlssOutputDir,
+		RubixNodeURL:  rubixNodeURL,
+		RubixNodeURLs: rubixNodeURLs,
+		RubixTimeout:  rubixTimeout,
+		RubixRetries:  rubixRetries,
+
+		RubixCAFile:       os.Getenv("RUBIX_CA_FILE"),
+		RubixClientCert:   os.Getenv("RUBIX_CLIENT_CERT"),
+		RubixClientKey:    os.Getenv("RUBIX_CLIENT_KEY"),
+		RubixAPIKey:       os.Getenv("RUBIX_API_KEY"),
+		RubixAPIKeyHeader: os.Getenv("RUBIX_API_KEY_HEADER"),
+		RubixBearerToken:  os.Getenv("RUBIX_BEARER_TOKEN"),
+		SenderDID:         os.Getenv("SENDER_DID"),
+		NLSSBasePath:      nlssBasePath,
+		NLSSNodeName:      nlssNodeName,
+		NLSSNodes:         os.Getenv("NLSS_NODES"),
+		NLSSDIDImageName:  nlssDIDImageName,
+		NLSSPubShareName:  nlssPubShareName,
+		NLSSPvtKeyName:    nlssPvtKeyName,
+		NLSSPubKeyName:    nlssPubKeyName,
+		NLSSOutputDir:     nlssOutputDir,
+
+		NLSSVaultDir:      os.Getenv("NLSS_VAULT_DIR"),
+		NLSSVaultPassword: os.Getenv("NLSS_VAULT_PASSWORD"),
+		NLSSVaultKeyFile:  os.Getenv("NLSS_VAULT_KEY_FILE"),
+
+		PrivateKeyPassword: os.Getenv("PRIVATE_KEY_PASSWORD"),
+
+		AuthSessionSecret: os.Getenv("AUTH_SESSION_SECRET"),
+
+		LedgerPath:   ledgerPath,
+		AuditLogPath: auditLogPath,
 	}
 
 	return config, nil
 }
 
+// defaultLedgerPath is the ledger used without LEDGER_PATH: one per user, in
+// the user's config directory, so transfers made from any working directory
+// share a journal. Without a config directory it sits in the NLSS output directory.
+func defaultLedgerPath(nlssOutputDir string) string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "break-nlss", "transfers.jsonl")
+	}
+	return filepath.Join(nlssOutputDir, "transfers.jsonl")
+}
+
 // LoadConfigWithOverrides loads configuration with command-line overrides
 func LoadConfigWithOverrides(rubixNode, senderDID string) (*Config, error) {
 	// Start with environment-based config
@@ -89,27 +206,74 @@ func (c *Config) PrintConfig() {
 	fmt.Println("Configuration:")
 	fmt.Printf("  Rubix Node URL: %s\n", c.RubixNodeURL)
 	fmt.Printf("  Sender DID: %s\n", c.SenderDID)
+	if c.NLSSNodeName != "" {
+		fmt.Printf("  NLSS Node: %s\n", c.NLSSNodeName)
+	}
 	fmt.Printf("  NLSS Output Dir: %s\n", c.NLSSOutputDir)
+	if c.VaultEnabled() {
+		fmt.Printf("  NLSS Vault Dir: %s\n", c.GetNLSSVaultDir())
+	}
 }
 
-// GetNLSSImagePaths constructs the full paths for DID and public share images
-// based on the configured base path, node name, and DID
-// Path format: {basePath}/{nodeName}/Rubix/{did}/{imageName}
-func (c *Config) GetNLSSImagePaths(did string) (didPath, pubSharePath string, err error) {
+// VaultEnabled reports whether private shares are kept in the encrypted vault
+func (c *Config) VaultEnabled() bool {
+	return c.NLSSVaultPassword != "" || c.NLSSVaultKeyFile != ""
+}
+
+// GetNLSSVaultDir returns the vault directory, defaulting to the output directory
+func (c *Config) GetNLSSVaultDir() string {
+	if c.NLSSVaultDir != "" {
+		return c.NLSSVaultDir
+	}
+	return c.NLSSOutputDir
+}
+
+// OpenVault opens the configured private share vault.
+// It returns nil without error when no vault password or key file is set.
+func (c *Config) OpenVault() (*vault.Vault, error) {
+	if !c.VaultEnabled() {
+		return nil, nil
+	}
+	if c.NLSSVaultPassword != "" && c.NLSSVaultKeyFile != "" {
+		return nil, fmt.Errorf("set only one of NLSS_VAULT_PASSWORD and NLSS_VAULT_KEY_FILE")
+	}
+
+	var v *vault.Vault
+	var err error
+	if c.NLSSVaultKeyFile != "" {
+		v, err = vault.OpenWithKeyFile(c.GetNLSSVaultDir(), c.NLSSVaultKeyFile)
+	} else {
+		v, err = vault.Open(c.GetNLSSVaultDir(), c.NLSSVaultPassword)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vault: %w", err)
+	}
+	return v, nil
+}
+
+// GetNLSSRubixDir returns the node directory that holds one folder per DID
+// Path format: {basePath}/{nodeName}/Rubix
+func (c *Config) GetNLSSRubixDir() (string, error) {
 	if c.NLSSBasePath == "" {
-		return "", "", fmt.Errorf("NLSS_BASE_PATH not configured in .env file")
+		return "", fmt.Errorf("NLSS_BASE_PATH not configured in .env file")
 	}
 	if c.NLSSNodeName == "" {
-		return "", "", fmt.Errorf("NLSS_NODE_NAME not configured in .env file")
+		return "", fmt.Errorf("NLSS_NODE_NAME not configured in .env file")
 	}
 
+	return filepath.Join(c.NLSSBasePath, c.NLSSNodeName, "Rubix"), nil
+}
+
+// GetNLSSImagePaths constructs the full paths for DID and public share images
+// based on the configured base path, node name, and DID
+// Path format: {basePath}/{nodeName}/Rubix/{did}/{imageName}
+func (c *Config) GetNLSSImagePaths(did string) (didPath, pubSharePath string, err error) {
 	// Construct base directory: /mnt/storage/bulkset/set1/bulk011/Rubix/{did}/
-	baseDir := filepath.Join(
-		c.NLSSBasePath,
-		c.NLSSNodeName,
-		"Rubix",
-		did,
-	)
+	rubixDir, err := c.GetNLSSRubixDir()
+	if err != nil {
+		return "", "", err
+	}
+	baseDir := filepath.Join(rubixDir, did)
 
 	// Construct full paths to images
 	didPath = filepath.Join(baseDir, c.NLSSDIDImageName)
@@ -118,6 +282,26 @@ func (c *Config) GetNLSSImagePaths(did string) (didPath, pubSharePath string, er
 	return didPath, pubSharePath, nil
 }
 
+// GetNLSSPvtKeyPath returns the EC private key a node keeps in the DID folder
+// Path format: {basePath}/{nodeName}/Rubix/{did}/{pvtKeyName}
+func (c *Config) GetNLSSPvtKeyPath(did string) (string, error) {
+	rubixDir, err := c.GetNLSSRubixDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rubixDir, did, c.NLSSPvtKeyName), nil
+}
+
+// GetNLSSPubKeyPath returns the EC public key a node keeps in the DID folder
+// Path format: {basePath}/{nodeName}/Rubix/{did}/{pubKeyName}
+func (c *Config) GetNLSSPubKeyPath(did string) (string, error) {
+	rubixDir, err := c.GetNLSSRubixDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rubixDir, did, c.NLSSPubKeyName), nil
+}
+
 // GetNLSSOutputPath constructs the output path for the private share
 // Output format: {outputDir}/{did}/pvtShare.png
 func (c *Config) GetNLSSOutputPath(did string) (string, error) {
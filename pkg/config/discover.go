@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// didFolderPrefix is the CID prefix shared by Rubix DIDs
+const didFolderPrefix = "bafybmi"
+
+// DIDFolder describes a DID directory found under the node's Rubix folder
+type DIDFolder struct {
+	DID         string
+	Node        string
+	Path        string
+	HasDIDImage bool
+	HasPubShare bool
+}
+
+// Complete reports whether the folder holds both images needed by break-nlss
+func (f DIDFolder) Complete() bool {
+	return f.HasDIDImage && f.HasPubShare
+}
+
+// Missing lists the image files the folder lacks
+func (f DIDFolder) Missing(c *Config) []string {
+	var missing []string
+	if !f.HasDIDImage {
+		missing = append(missing, c.NLSSDIDImageName)
+	}
+	if !f.HasPubShare {
+		missing = append(missing, c.NLSSPubShareName)
+	}
+	return missing
+}
+
+// DiscoverDIDs walks {basePath}/{nodeName}/Rubix and returns every DID folder,
+// sorted by name. A folder counts as a DID folder when its name looks like a
+// DID or it holds at least one of the DID image and public share.
+func (c *Config) DiscoverDIDs() ([]DIDFolder, error) {
+	rubixDir, err := c.GetNLSSRubixDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(rubixDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node directory: %w", err)
+	}
+
+	var folders []DIDFolder
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(rubixDir, entry.Name())
+		folder := DIDFolder{
+			DID:         entry.Name(),
+			Node:        c.NLSSNodeName,
+			Path:        dir,
+			HasDIDImage: fileExists(filepath.Join(dir, c.NLSSDIDImageName)),
+			HasPubShare: fileExists(filepath.Join(dir, c.NLSSPubShareName)),
+		}
+
+		if !strings.HasPrefix(folder.DID, didFolderPrefix) && !folder.HasDIDImage && !folder.HasPubShare {
+			continue
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, nil
+}
+
+// fileExists reports whether path exists and is a regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
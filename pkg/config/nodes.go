@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// parseNodeURLs parses "node=url,node=url" into a map
+func parseNodeURLs(value string) (map[string]string, error) {
+	urls := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		node, url, ok := strings.Cut(pair, "=")
+		node, url = strings.TrimSpace(node), strings.TrimSpace(url)
+		if !ok || node == "" || url == "" {
+			return nil, fmt.Errorf("invalid RUBIX_NODE_URLS entry %q (expected node=url)", pair)
+		}
+		urls[node] = url
+	}
+	return urls, nil
+}
+
+// ResolveNodes expands a comma-separated list of node names and glob patterns
+// (e.g. "bulk0*" or "bulk011,bulk012") into the node directories under
+// NLSS_BASE_PATH that contain a Rubix folder. The result is sorted.
+func (c *Config) ResolveNodes(spec string) ([]string, error) {
+	if c.NLSSBasePath == "" {
+		return nil, fmt.Errorf("NLSS_BASE_PATH not configured in .env file")
+	}
+
+	seen := make(map[string]bool)
+	for _, pattern := range strings.Split(spec, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(c.NLSSBasePath, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid node pattern %q: %w", pattern, err)
+		}
+
+		found := false
+		for _, match := range matches {
+			info, err := os.Stat(filepath.Join(match, "Rubix"))
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			seen[filepath.Base(match)] = true
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("no node directories match %q under %s", pattern, c.NLSSBasePath)
+		}
+	}
+
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("no nodes specified")
+	}
+
+	nodes := make([]string, 0, len(seen))
+	for node := range seen {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// ForNode returns a copy of the configuration scoped to one NLSS node,
+// using that node's Rubix URL from RUBIX_NODE_URLS when one is mapped
+func (c *Config) ForNode(node string) *Config {
+	nodeConfig := *c
+	nodeConfig.NLSSNodeName = node
+	if url, ok := c.RubixNodeURLs[node]; ok {
+		nodeConfig.RubixNodeURL = url
+	}
+	return &nodeConfig
+}
+
+// FindDIDNode returns the first of nodes whose Rubix folder holds a directory for did
+func (c *Config) FindDIDNode(did string, nodes []string) (string, error) {
+	if found := c.DIDNodes(did, nodes); len(found) > 0 {
+		return found[0], nil
+	}
+	return "", fmt.Errorf("DID %s not found on any of %d nodes", did, len(nodes))
+}
+
+// DIDNodes returns every node holding a folder for the DID, in the order given
+func (c *Config) DIDNodes(did string, nodes []string) []string {
+	var found []string
+	for _, node := range nodes {
+		info, err := os.Stat(filepath.Join(c.NLSSBasePath, node, "Rubix", did))
+		if err == nil && info.IsDir() {
+			found = append(found, node)
+		}
+	}
+	return found
+}
@@ -1,7 +1,6 @@
 package crypto
 
 import (
-	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/ecdsa"
@@ -21,6 +20,36 @@ func GenerateECKeyPair() (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 }
 
+// Seal encrypts data using AES-GCM with password-derived key.
+// The output is nonce || ciphertext, the layout UnSeal expects.
+// Reference: /Users/allen/Professional/rubixgoplatform/crypto/seal.go:12-30
+func Seal(password string, data []byte) ([]byte, error) {
+	// Hash password to get AES key
+	h := sha256.New()
+	h.Write([]byte(password))
+	key := h.Sum(nil)
+
+	// Create AES cipher
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create GCM mode
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Random nonce is prepended to the ciphertext
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
 // UnSeal decrypts data using AES-GCM with password-derived key
 // Reference: /Users/allen/Professional/rubixgoplatform/crypto/seal.go:32-51
 func UnSeal(password string, data []byte) ([]byte, error) {
@@ -92,6 +121,15 @@ func LoadPrivateKeyFromPEMWithPassword(filepath, password string) (*ecdsa.Privat
 		keyBytes = block.Bytes
 	}
 
+	// Keys written by SavePrivateKeyToPEM use the SEC1 "EC PRIVATE KEY" format
+	if block.Type == "EC PRIVATE KEY" {
+		ecKey, err := x509.ParseECPrivateKey(keyBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EC private key: %w", err)
+		}
+		return ecKey, nil
+	}
+
 	// Parse as PKCS8 (matching rubixgoplatform)
 	cryptoPrivKey, err := x509.ParsePKCS8PrivateKey(keyBytes)
 	if err != nil {
@@ -137,14 +175,38 @@ func SavePublicKeyToPEM(publicKey *ecdsa.PublicKey, filepath string) error {
 	return os.WriteFile(filepath, pemEncoded, 0644)
 }
 
+// LoadPublicKeyFromPEM loads an EC public key from a PEM file
+func LoadPublicKeyFromPEM(filepath string) (*ecdsa.PublicKey, error) {
+	pemData, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PEM file: %w", err)
+	}
+
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	ecKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not an EC public key (found %T)", pub)
+	}
+
+	return ecKey, nil
+}
+
 // SignWithECDSA signs data with an ECDSA private key
-// Uses crypto.Signer interface with SHA256 (matching rubixgoplatform)
+// The data is used as the digest directly and truncated to the curve size,
+// which is what rubixgoplatform's crypto.Signer call does on the node side
 // Reference: /Users/allen/Professional/rubixgoplatform/crypto/crypto.go:131-133
 func SignWithECDSA(privateKey *ecdsa.PrivateKey, data []byte) ([]byte, error) {
-	// Use crypto.Signer interface (same as rubixgoplatform)
-	// This automatically handles SHA256 hashing and ASN.1 DER encoding
-	signer := crypto.Signer(privateKey)
-	signature, err := signer.Sign(rand.Reader, data, crypto.SHA256)
+	// SignASN1 produces the ASN.1 DER encoding expected by VerifyASN1
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign: %w", err)
 	}
@@ -0,0 +1,122 @@
+package nlss
+
+import (
+	"crypto/rand"
+	"crypto/sha3"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+)
+
+// Standard image dimensions used by Rubix DIDs
+const (
+	DIDImageWidth    = 256
+	DIDImageHeight   = 256
+	ShareImageWidth  = 1024
+	ShareImageHeight = 512
+)
+
+// Standard file names inside a DID folder
+const (
+	DIDImageFileName = "did.png"
+	PubShareFileName = "pubShare.png"
+	PvtShareFileName = "pvtShare.png"
+)
+
+// Shares holds a DID image together with its public and private shares as RGB pixel data
+type Shares struct {
+	DID []byte
+	Pub []byte
+	Pvt []byte
+}
+
+// NewSeededReader returns a deterministic random source derived from seed (SHAKE-256)
+func NewSeededReader(seed []byte) io.Reader {
+	h := sha3.NewSHAKE256()
+	h.Write(seed)
+	return h
+}
+
+// GenerateShares splits DID bytes into a public and a private share.
+// Every DID bit i is carried by byte i of both shares so that
+// popcount(pub[i] & pvt[i]) % 2 equals the bit (the relation VerifyPVT checks).
+// If r is nil, crypto/rand is used.
+func GenerateShares(didBytes []byte, r io.Reader) (pubBytes, pvtBytes []byte, err error) {
+	if r == nil {
+		r = rand.Reader
+	}
+
+	n := len(didBytes) * 8
+	pubBytes = make([]byte, n)
+	pvtBytes = make([]byte, n)
+	if _, err := io.ReadFull(r, pubBytes); err != nil {
+		return nil, nil, fmt.Errorf("failed to read random public share: %w", err)
+	}
+	if _, err := io.ReadFull(r, pvtBytes); err != nil {
+		return nil, nil, fmt.Errorf("failed to read random private share: %w", err)
+	}
+
+	var one [1]byte
+	for i := 0; i < n; i++ {
+		// A zero public byte can never carry a set bit, so draw it again
+		for pubBytes[i] == 0 {
+			if _, err := io.ReadFull(r, one[:]); err != nil {
+				return nil, nil, fmt.Errorf("failed to read random public share: %w", err)
+			}
+			pubBytes[i] = one[0]
+		}
+
+		bit := (didBytes[i>>3] >> (7 - uint(i&7))) & 1
+		if byte(bits.OnesCount8(pubBytes[i]&pvtBytes[i])&1) != bit {
+			// Flip the private bit under the lowest set public bit to fix the parity
+			pvtBytes[i] ^= pubBytes[i] & -pubBytes[i]
+		}
+	}
+
+	return pubBytes, pvtBytes, nil
+}
+
+// GenerateDID creates a new random DID image and its public and private shares.
+// If r is nil, crypto/rand is used; pass NewSeededReader for reproducible output.
+func GenerateDID(r io.Reader) (*Shares, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+
+	didBytes := make([]byte, DIDImageWidth*DIDImageHeight*3)
+	if _, err := io.ReadFull(r, didBytes); err != nil {
+		return nil, fmt.Errorf("failed to read random DID image: %w", err)
+	}
+
+	pubBytes, pvtBytes, err := GenerateShares(didBytes, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Shares{
+		DID: didBytes,
+		Pub: pubBytes,
+		Pvt: pvtBytes,
+	}, nil
+}
+
+// SaveShares writes did.png, pubShare.png and pvtShare.png into dir
+func SaveShares(s *Shares, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if err := CreatePNGImage(s.DID, DIDImageWidth, DIDImageHeight, filepath.Join(dir, DIDImageFileName)); err != nil {
+		return fmt.Errorf("failed to create DID image: %w", err)
+	}
+	if err := CreatePNGImage(s.Pub, ShareImageWidth, ShareImageHeight, filepath.Join(dir, PubShareFileName)); err != nil {
+		return fmt.Errorf("failed to create public share: %w", err)
+	}
+	if err := CreatePrivatePNGImage(s.Pvt, ShareImageWidth, ShareImageHeight, filepath.Join(dir, PvtShareFileName)); err != nil {
+		return fmt.Errorf("failed to create private share: %w", err)
+	}
+
+	return nil
+}
@@ -6,15 +6,22 @@ import (
 	"bytes"
 	"crypto/sha3"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"math/bits"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// ErrVerificationFailed is returned when a reconstructed private share does not
+// reproduce the DID image
+var ErrVerificationFailed = errors.New("private share verification failed")
+
 // RandPos represents random positions for signing and verification
 type RandPos struct {
 	OriginalPos []int `json:"originalPos"`
@@ -23,145 +30,139 @@ type RandPos struct {
 
 // BreakNLSS reconstructs a private share from DID and public share bytes
 func BreakNLSS(didBytes, pubBytes []byte) ([]byte, error) {
-	fmt.Printf("[DEBUG] BreakNLSS started - converting bytes to bit strings...\n")
-	didBits := ConvertToBitString(didBytes)
-	fmt.Printf("[DEBUG] DID converted to %d bits\n", len(didBits))
-	pubBits := ConvertToBitString(pubBytes)
-	fmt.Printf("[DEBUG] Public share converted to %d bits\n", len(pubBits))
-
-	if len(pubBits) < 8*len(didBits) {
-		return nil, fmt.Errorf("pubBits too small: got %d, need %d", len(pubBits), 8*len(didBits))
-	}
-
 	privateBytes := make([]byte, len(pubBytes))
-	temp := ""
-
-	fmt.Printf("[DEBUG] Processing %d bits...\n", len(didBits))
-
-	for i := 0; i < len(didBits); i++ {
-		// Progress indicator every 50000 iterations
-		if i > 0 && i%50000 == 0 {
-			fmt.Printf("[DEBUG] Progress: %d/%d (%.0f%%)\n", i, len(didBits), float64(i)/float64(len(didBits))*100)
-		}
-		didBit := didBits[i]
-		temp = ""
-
-		start := 8 * i
-		end := start + 8
-
-		for k := start; k < end; k++ {
-			sum := (int(didBit-'0') * int(pubBits[k]-'0')) % 2
-			temp = ConvertString(temp, sum)
-		}
-
-		pvtCandidate := ConvertBitString(temp)[0]
-		pubShareValue := pubBytes[i]
+	if err := BreakNLSSInto(privateBytes, didBytes, pubBytes); err != nil {
+		return nil, err
+	}
+	return privateBytes, nil
+}
 
-		for {
-			x := pubShareValue & pvtCandidate
-			cnt := 0
-			for y := x; y != 0; y &= (y - 1) {
-				cnt++
-			}
-			computed := cnt % 2
-			expected := int(didBit - '0')
+// BreakNLSSInto reconstructs a private share into dst without allocating.
+// DID bit i is rebuilt from public share byte i, so dst needs at least
+// 8*len(didBytes) bytes; anything past that is left untouched.
+func BreakNLSSInto(dst, didBytes, pubBytes []byte) error {
+	n := 8 * len(didBytes)
+	if len(pubBytes) < n {
+		return fmt.Errorf("public share too small: got %d bytes, need %d", len(pubBytes), n)
+	}
+	if len(dst) < n {
+		return fmt.Errorf("private share buffer too small: got %d, need %d", len(dst), n)
+	}
 
-			if computed == expected {
-				privateBytes[i] = pvtCandidate
-				break
+	for i := 0; i < n; i++ {
+		bit := (didBytes[i>>3] >> (7 - uint(i&7))) & 1
+		pub := pubBytes[i]
+
+		// Start from the public byte masked by the DID bit and take the first
+		// candidate whose parity with the public byte matches the DID bit
+		candidate := pub & -bit
+		tries := 0
+		for byte(bits.OnesCount8(pub&candidate)&1) != bit {
+			candidate++
+			tries++
+			if tries == 256 {
+				return fmt.Errorf("no private share byte for DID bit %d: public share byte is zero", i)
 			}
-			pvtCandidate++
 		}
+		dst[i] = candidate
 	}
 
-	fmt.Printf("[DEBUG] Processing complete!\n")
-
-	return privateBytes, nil
+	return nil
 }
 
-// BreakNLSSFromFiles reconstructs a private share from DID and public share image files
-func BreakNLSSFromFiles(didPath, pubSharePath, outputPath string) error {
+// ReconstructFromFiles rebuilds and verifies a private share from DID and public
+// share image files, keeping it in memory only. Callers should Wipe the result
+// once they are done with it.
+func ReconstructFromFiles(didPath, pubSharePath string) ([]byte, error) {
 	didBytes, err := GetPNGImagePixels(didPath)
 	if err != nil {
-		return fmt.Errorf("failed to load DID image: %w", err)
+		return nil, fmt.Errorf("failed to load DID image: %w", err)
 	}
 
 	pubBytes, err := GetPNGImagePixels(pubSharePath)
 	if err != nil {
-		return fmt.Errorf("failed to load public share image: %w", err)
+		return nil, fmt.Errorf("failed to load public share image: %w", err)
 	}
 
-	fmt.Printf("Loaded DID bytes: %d\n", len(didBytes))
-	fmt.Printf("Loaded public share bytes: %d\n", len(pubBytes))
-
 	pvtBytes, err := BreakNLSS(didBytes, pubBytes)
 	if err != nil {
-		return fmt.Errorf("BreakNLSS failed: %w", err)
+		return nil, fmt.Errorf("BreakNLSS failed: %w", err)
 	}
 
-	fmt.Printf("Generated private share bytes: %d\n", len(pvtBytes))
+	if err := CheckPVT(didBytes, pubBytes, pvtBytes); err != nil {
+		Wipe(pvtBytes)
+		return nil, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
 
-	verified := VerifyPVT(didBytes, pubBytes, pvtBytes)
-	fmt.Printf("Verification: %v\n", verified)
+	return pvtBytes, nil
+}
 
-	if !verified {
-		return fmt.Errorf("private share verification failed")
+// BreakNLSSFromFiles reconstructs a private share from DID and public share image files.
+// It does not print, so it can safely run on several goroutines at once.
+func BreakNLSSFromFiles(didPath, pubSharePath, outputPath string) error {
+	pvtBytes, err := ReconstructFromFiles(didPath, pubSharePath)
+	if err != nil {
+		return err
 	}
+	defer Wipe(pvtBytes)
 
-	// Create PNG output (1024x512 is standard size)
-	err = CreatePNGImage(pvtBytes, 1024, 512, outputPath)
+	// Create PNG output (1024x512 is standard size); the plaintext share must
+	// not be readable by other users
+	err = CreatePrivatePNGImage(pvtBytes, ShareImageWidth, ShareImageHeight, outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output PNG: %w", err)
 	}
 
-	fmt.Printf("Private share saved to: %s\n", outputPath)
 	return nil
 }
 
 // VerifyPVT verifies that the private share correctly reconstructs the DID
 func VerifyPVT(didBytes, pubBytes, pvtBytes []byte) bool {
-	didBits := ConvertToBitString(didBytes)
+	return CheckPVT(didBytes, pubBytes, pvtBytes) == nil
+}
 
-	for i := 0; i < len(didBits); i++ {
-		didBit := didBits[i]
-		pubMask := pubBytes[i]
-		pvtByte := pvtBytes[i]
+// CheckPVT is VerifyPVT reporting why a private share does not reconstruct
+// the DID. It does not print, so it can run on several goroutines at once.
+func CheckPVT(didBytes, pubBytes, pvtBytes []byte) error {
+	n := 8 * len(didBytes)
+	if len(pubBytes) < n || len(pvtBytes) < n {
+		return fmt.Errorf("share too small: need %d bytes, got public %d and private %d", n, len(pubBytes), len(pvtBytes))
+	}
 
-		x := pubMask & pvtByte
-		cnt := 0
-		for y := x; y != 0; y &= (y - 1) {
-			cnt++
+	for j, didByte := range didBytes {
+		computed := combineByte(pvtBytes[8*j:8*j+8], pubBytes[8*j:8*j+8])
+		if computed == didByte {
+			continue
 		}
-		computed := cnt % 2
-		expected := int(didBit - '0')
 
-		if computed != expected {
-			fmt.Printf("Mismatch at bit %d: expected %d got %d\n", i, expected, computed)
-			return false
-		}
+		// Report the first mismatching bit within this byte
+		diff := computed ^ didByte
+		k := bits.LeadingZeros8(diff)
+		return fmt.Errorf("mismatch at bit %d: expected %d got %d", 8*j+k, (didByte>>(7-k))&1, (computed>>(7-k))&1)
 	}
 
-	return true
+	return nil
 }
 
 // Combine2Shares combines two shares using XOR-like operation
 func Combine2Shares(pvt []byte, pub []byte) []byte {
-	pvtString := ConvertToBitString(pvt)
-	pubString := ConvertToBitString(pub)
-	if len(pvtString) != len(pubString) {
+	if len(pvt) != len(pub) || len(pvt)%8 != 0 {
 		return nil
 	}
-	var sum int
-	var temp string = ""
-	for i := 0; i < len(pvtString); i = i + 8 {
-		sum = 0
-		for j := i; j < i+8; j++ {
-			sum = sum + (int(pvtString[j]-0x30) * int(pubString[j]-0x30))
-		}
-		sum = sum % 2
-		temp = ConvertString(temp, sum)
+	out := make([]byte, len(pvt)/8)
+	for j := range out {
+		out[j] = combineByte(pvt[8*j:8*j+8], pub[8*j:8*j+8])
+	}
+	return out
+}
+
+// combineByte packs the parities of eight pvt&pub byte pairs into one byte, MSB first
+func combineByte(pvt, pub []byte) byte {
+	var b byte
+	for k := 0; k < 8; k++ {
+		b = b<<1 | byte(bits.OnesCount8(pvt[k]&pub[k])&1)
 	}
-	return (ConvertBitString(temp))
+	return b
 }
 
 // Sign generates a signature from the private share
@@ -170,19 +171,64 @@ func Sign(pvtSharePath string, hash string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read private share: %w", err)
 	}
+	defer Wipe(byteImg)
+
+	return SignShare(byteImg, hash)
+}
+
+// SignShare generates a signature from private share pixel data held in memory.
+// The expanded bit array is wiped before returning; pvtBytes is left to the caller.
+func SignShare(pvtBytes []byte, hash string) ([]byte, error) {
+	ps := ByteArraytoIntArray(pvtBytes)
+	defer wipeInts(ps)
 
-	ps := ByteArraytoIntArray(byteImg)
 	randPosObject := RandomPositions("signer", hash, 32, ps)
+	if randPosObject == nil {
+		return nil, fmt.Errorf("invalid hash for signing: %q", hash)
+	}
 	finalPos := randPosObject.PosForSign
 	pvtPos := GetPrivatePositions(finalPos, ps)
 	pvtPosStr := IntArraytoStr(pvtPos)
 	bs := BitstreamToBytes(pvtPosStr)
+	wipeInts(pvtPos)
 
 	return bs, nil
 }
 
-// NlssVerify verifies an NLSS signature
+// Wipe overwrites secret bytes with zeros
+func Wipe(b []byte) {
+	clear(b)
+}
+
+// wipeInts overwrites an expanded bit array with zeros
+func wipeInts(a []int) {
+	clear(a)
+}
+
+// SignatureSize is the length of an NLSS signature: 256 private share bits
+const SignatureSize = 32
+
+// ErrMalformedSignature is returned for a signature or hash that cannot be verified
+var ErrMalformedSignature = errors.New("malformed signature")
+
+// CheckSignatureFormat reports whether sig and hash have the shape NlssVerify
+// expects: SignatureSize bytes and a 64 character hex hash
+func CheckSignatureFormat(hash string, sig []byte) error {
+	if len(sig) != SignatureSize {
+		return fmt.Errorf("%w: got %d bytes, want %d", ErrMalformedSignature, len(sig), SignatureSize)
+	}
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != 64 {
+		return fmt.Errorf("%w: hash %q is not 64 hex characters", ErrMalformedSignature, hash)
+	}
+	return nil
+}
+
+// NlssVerify verifies an NLSS signature. A malformed signature or hash is
+// rejected with an error matching ErrMalformedSignature.
 func NlssVerify(didPath, pubSharePath string, hash string, pvtShareSig []byte) (bool, error) {
+	if err := CheckSignatureFormat(hash, pvtShareSig); err != nil {
+		return false, err
+	}
 	didImg, err := GetPNGImagePixels(didPath)
 	if err != nil {
 		return false, err
@@ -243,8 +289,55 @@ func GetPNGImagePixels(file string) ([]byte, error) {
 
 // CreatePNGImage creates a PNG file from RGB pixel data
 func CreatePNGImage(pixels []byte, width int, height int, file string) error {
+	img, err := pixelImage(pixels, width, height)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreatePrivatePNGImage writes an image only its owner can read. It is encoded
+// into a 0600 temporary file that is renamed into place, so the file is never
+// readable by others, even briefly.
+func CreatePrivatePNGImage(pixels []byte, width int, height int, file string) error {
+	img, err := pixelImage(pixels, width, height)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// pixelImage builds an opaque image from RGB pixel data
+func pixelImage(pixels []byte, width int, height int) (*image.RGBA, error) {
 	if len(pixels) != width*height*3 {
-		return fmt.Errorf("invalid pixel buffer: got %d bytes, expected %d", len(pixels), width*height*3)
+		return nil, fmt.Errorf("invalid pixel buffer: got %d bytes, expected %d", len(pixels), width*height*3)
 	}
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
 	offset := 0
@@ -259,16 +352,7 @@ func CreatePNGImage(pixels []byte, width int, height int, file string) error {
 			offset = offset + 3
 		}
 	}
-
-	f, err := os.Create(file)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	if err := png.Encode(f, img); err != nil {
-		return err
-	}
-	return nil
+	return img, nil
 }
 
 // ConvertToBitString converts bytes to binary string
@@ -0,0 +1,175 @@
+package rubix
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"break-nlss/pkg/crypto"
+)
+
+// TransferBundleVersion is the current transfer bundle format
+const TransferBundleVersion = "1"
+
+// ErrBundleMismatch is returned when a bundle's contents do not match its binding digests
+var ErrBundleMismatch = errors.New("transfer bundle does not match its request")
+
+// TransferBundle carries an initiated transfer between the prepare, sign and
+// submit steps of the offline workflow. Binding is a SHA3-256 digest over the
+// request, so every step can check the bundle still belongs to it.
+type TransferBundle struct {
+	Version      string    `json:"version"`
+	RequestID    string    `json:"request_id"`
+	Hash         string    `json:"hash"` // decoded hash to sign
+	SenderDID    string    `json:"sender_did"`
+	ReceiverDID  string    `json:"receiver_did"`
+	Amount       float64   `json:"amount"`
+	Comment      string    `json:"comment"`
+	RubixNodeURL string    `json:"rubix_node_url"`
+	NodeName     string    `json:"node_name,omitempty"`
+	Signer       string    `json:"signer"` // signer kind the sender's DID needs
+	PreparedAt   time.Time `json:"prepared_at"`
+	Binding      string    `json:"binding"`
+
+	// Identify the transfer in the sender's ledger
+	TransferID     string `json:"transfer_id,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// Set by the sign step
+	Signature        *SignatureData `json:"signature,omitempty"`
+	SignedAt         *time.Time     `json:"signed_at,omitempty"`
+	SignatureBinding string         `json:"signature_binding,omitempty"`
+}
+
+// NewTransferBundle creates an unsigned bundle for an initiated transfer
+func NewTransferBundle(params TransferParams, pending *PendingTransfer) *TransferBundle {
+	b := &TransferBundle{
+		Version:      TransferBundleVersion,
+		RequestID:    pending.RequestID,
+		Hash:         pending.Hash,
+		SenderDID:    params.SenderDID,
+		ReceiverDID:  params.ReceiverDID,
+		Amount:       params.Amount,
+		Comment:      params.Comment,
+		RubixNodeURL: params.RubixNodeURL,
+		PreparedAt:   time.Now(),
+	}
+	if pending.NodeURL != "" {
+		// Request IDs are node-local: submit to the node that initiated the transfer
+		b.RubixNodeURL = pending.NodeURL
+	}
+	b.Binding = b.computeBinding()
+	return b
+}
+
+// computeBinding digests the request fields that a signature is made for
+func (b *TransferBundle) computeBinding() string {
+	fields := []string{
+		"break-nlss transfer bundle v" + b.Version,
+		b.RequestID,
+		b.Hash,
+		b.SenderDID,
+		b.ReceiverDID,
+		strconv.FormatFloat(b.Amount, 'f', -1, 64),
+		b.Comment,
+	}
+	return crypto.CalculateSHA3Hash(strings.Join(fields, "\n"))
+}
+
+// computeSignatureBinding digests the request binding together with the signatures
+func (b *TransferBundle) computeSignatureBinding() string {
+	fields := []string{
+		b.Binding,
+		base64.StdEncoding.EncodeToString(b.Signature.Signature),
+		base64.StdEncoding.EncodeToString(b.Signature.Pixels),
+	}
+	return crypto.CalculateSHA3Hash(strings.Join(fields, "\n"))
+}
+
+// Check verifies the bundle against its binding digests
+func (b *TransferBundle) Check() error {
+	if b.Version != TransferBundleVersion {
+		return fmt.Errorf("unsupported transfer bundle version %q", b.Version)
+	}
+	if b.RequestID == "" || b.Hash == "" {
+		return fmt.Errorf("transfer bundle has no request ID or hash")
+	}
+	if b.Binding != b.computeBinding() {
+		return fmt.Errorf("%w: request fields were modified", ErrBundleMismatch)
+	}
+	if b.Signed() && b.SignatureBinding != b.computeSignatureBinding() {
+		return fmt.Errorf("%w: signature was not made for this request", ErrBundleMismatch)
+	}
+	return nil
+}
+
+// Signed reports whether the sign step has attached signatures
+func (b *TransferBundle) Signed() bool {
+	return b.Signature != nil
+}
+
+// Attach adds signatures made for the bundle's hash
+func (b *TransferBundle) Attach(sig *SignatureData) {
+	now := time.Now()
+	b.Signature = sig
+	b.SignedAt = &now
+	b.SignatureBinding = b.computeSignatureBinding()
+}
+
+// Result returns a transfer result for the bundle's request, not yet submitted
+func (b *TransferBundle) Result() *TransferResult {
+	r := &TransferResult{
+		ID:             b.TransferID,
+		IdempotencyKey: b.IdempotencyKey,
+		RequestID:      b.RequestID,
+		NodeURL:        b.RubixNodeURL,
+		SenderDID:      b.SenderDID,
+		ReceiverDID:    b.ReceiverDID,
+		Amount:         b.Amount,
+		Comment:        b.Comment,
+		Hash:           b.Hash,
+		State:          TransferInitiated,
+		CreatedAt:      b.PreparedAt,
+		UpdatedAt:      time.Now(),
+	}
+	if b.Signed() {
+		r.State, r.Signature = TransferSigned, b.Signature
+	}
+	return r
+}
+
+// SaveTransferBundle writes a bundle as JSON, readable by the owner only
+func SaveTransferBundle(path string, b *TransferBundle) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transfer bundle: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write transfer bundle: %w", err)
+	}
+	return nil
+}
+
+// LoadTransferBundle reads a bundle and checks its binding digests
+func LoadTransferBundle(path string) (*TransferBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer bundle: %w", err)
+	}
+
+	var b TransferBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse transfer bundle: %w", err)
+	}
+
+	if err := b.Check(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
@@ -2,64 +2,207 @@ package rubix
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
+// Default per-call timeout and retry policy of NewClient
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultMaxRetries = 3
+)
+
+// RetryPolicy controls how failed calls are retried with exponential backoff.
+//
+// Idempotent calls (GetBalance, GetAllDID, GetTransactionsByDID) are retried
+// on network errors, timeouts, HTTP 429 and 5xx responses. Non-idempotent calls
+// (InitiateTransfer, SubmitSignature) are retried only when the connection could
+// not be established, so a request the node may have processed is never sent twice.
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt; 0 disables retries
+	BaseDelay  time.Duration // delay before the first retry, doubled for each further retry
+	MaxDelay   time.Duration // upper bound on the delay between retries
+}
+
+// DefaultRetryPolicy returns the retry policy used by NewClient
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxRetries: DefaultMaxRetries, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second}
+}
+
+// backoff returns the delay before retry number n (starting at 1)
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < n && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
 // Client represents a Rubix blockchain HTTP client
 type Client struct {
+	// BaseURL is the node address: host:port (plain HTTP) or a full URL
+	// such as https://node.example.com/rubix
 	BaseURL    string
 	HTTPClient *http.Client
+
+	// Endpoints lists equivalent nodes when there is more than one. Read calls
+	// rotate over them and fail over to the next node; transfer calls go to
+	// BaseURL, which Pin sets to a healthy node.
+	Endpoints []string
+	// Cooldown is how long a failed node is skipped; 0 means DefaultNodeCooldown
+	Cooldown time.Duration
+	pool     *nodePool
+
+	// Header is added to every request, e.g. API key or bearer token headers
+	Header http.Header
+
+	// Timeout bounds each attempt of a call; 0 leaves only the context deadline
+	Timeout time.Duration
+	Retry   RetryPolicy
 }
 
-// NewClient creates a new Rubix client
+// NewClient creates a new Rubix client. baseURL may be a comma-separated
+// list of equivalent nodes, e.g. "node1:20006,node2:20006".
 func NewClient(baseURL string) *Client {
-	return &Client{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+	c := &Client{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{},
+		Timeout:    DefaultTimeout,
+		Retry:      DefaultRetryPolicy(),
+	}
+	if urls := SplitNodeURLs(baseURL); len(urls) > 1 {
+		c.BaseURL = urls[0]
+		c.Endpoints = urls
+		c.pool = newNodePool()
 	}
+	return c
 }
 
-// InitiateTransfer initiates a token transfer and returns the hash to sign
-// Reference: /Users/allen/Professional/sky/lib/native_interaction/rubix/rubix_platform_calls.dart:113-152
-func (c *Client) InitiateTransfer(req InitiateTransferRequest) (*InitiateTransferResponse, error) {
-	url := fmt.Sprintf("http://%s/api/initiate-rbt-transfer", c.BaseURL)
+// DefaultAPIKeyHeader is the header ClientOptions.APIKey is sent in by default
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// ClientOptions configures TLS and authentication for nodes behind
+// HTTPS reverse proxies or API gateways
+type ClientOptions struct {
+	CAFile       string // PEM bundle of CAs to trust in addition to the system pool
+	CertFile     string // client certificate PEM for mutual TLS
+	KeyFile      string // client private key PEM for mutual TLS
+	APIKey       string // sent in APIKeyHeader
+	APIKeyHeader string // default X-API-Key
+	BearerToken  string // sent as "Authorization: Bearer <token>"
+}
 
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+// NewClientWithOptions creates a client with custom TLS settings and auth headers
+func NewClientWithOptions(baseURL string, opts ClientOptions) (*Client, error) {
+	c := NewClient(baseURL)
+
+	if opts.CAFile != "" || opts.CertFile != "" || opts.KeyFile != "" {
+		tlsConfig, err := opts.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		c.HTTPClient.Transport = transport
 	}
 
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	c.Header = make(http.Header)
+	if opts.APIKey != "" {
+		header := opts.APIKeyHeader
+		if header == "" {
+			header = DefaultAPIKeyHeader
+		}
+		c.Header.Set(header, opts.APIKey)
+	}
+	if opts.BearerToken != "" {
+		c.Header.Set("Authorization", "Bearer "+opts.BearerToken)
 	}
+	return c, nil
+}
 
-	httpReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
+// tlsConfig builds the TLS configuration for the CA bundle and client certificate
+func (o ClientOptions) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if o.CAFile != "" {
+		pemData, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
 
-	resp, err := c.HTTPClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key must be given together")
+	}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	return tlsConfig, nil
+}
+
+// nodeURL joins a node address and an API path, defaulting to plain HTTP for host:port addresses
+func nodeURL(node, path string) string {
+	base := strings.TrimSuffix(node, "/")
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
 	}
+	return base + path
+}
+
+// API endpoints called by the client
+const (
+	endpointInitiate    = "/api/initiate-rbt-transfer"
+	endpointSignature   = "/api/signature-response"
+	endpointAccountInfo = "/api/get-account-info"
+	endpointAllDID      = "/api/getalldid"
+	endpointTxnByDID    = "/api/get-by-did"
+	endpointTxnByID     = "/api/get-by-txnId"
+	endpointReqStatus   = "/api/request-status"
+)
 
+// InitiateTransfer initiates a token transfer and returns the hash to sign
+// Reference: /Users/allen/Professional/sky/lib/native_interaction/rubix/rubix_platform_calls.dart:113-152
+func (c *Client) InitiateTransfer(req InitiateTransferRequest) (*InitiateTransferResponse, error) {
+	return c.InitiateTransferContext(context.Background(), req)
+}
+
+// InitiateTransferContext is InitiateTransfer with a context
+func (c *Client) InitiateTransferContext(ctx context.Context, req InitiateTransferRequest) (*InitiateTransferResponse, error) {
 	var response InitiateTransferResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	status, err := c.do(ctx, http.MethodPost, endpointInitiate, "", req, false, &response)
+	if err != nil {
+		return nil, err
 	}
 
 	if !response.Status {
-		return nil, fmt.Errorf("initiate transfer failed: %s", response.Message)
+		return nil, statusError(endpointInitiate, status, response.Message)
 	}
 
 	return &response, nil
@@ -68,110 +211,242 @@ func (c *Client) InitiateTransfer(req InitiateTransferRequest) (*InitiateTransfe
 // SubmitSignature submits the signatures to complete the transfer
 // Reference: /Users/allen/Professional/sky/lib/native_interaction/rubix/rubix_platform_calls.dart:202-229
 func (c *Client) SubmitSignature(req SignatureRequest) (*SignatureResponse, error) {
-	url := fmt.Sprintf("http://%s/api/signature-response", c.BaseURL)
+	return c.SubmitSignatureContext(context.Background(), req)
+}
 
-	reqBody, err := json.Marshal(req)
+// SubmitSignatureContext is SubmitSignature with a context
+func (c *Client) SubmitSignatureContext(ctx context.Context, req SignatureRequest) (*SignatureResponse, error) {
+	var response SignatureResponse
+	status, err := c.do(ctx, http.MethodPost, endpointSignature, "", req, false, &response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if !response.Status {
+		return nil, statusError(endpointSignature, status, response.Message)
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	return &response, nil
+}
 
-	resp, err := c.HTTPClient.Do(httpReq)
+// GetBalance retrieves the account balance for a DID
+// Reference: /Users/allen/Professional/sky/lib/native_interaction/rubix/rubix_platform_calls.dart:231-261
+func (c *Client) GetBalance(did string) (*GetBalanceResponse, error) {
+	return c.GetBalanceContext(context.Background(), did)
+}
+
+// GetBalanceContext is GetBalance with a context
+func (c *Client) GetBalanceContext(ctx context.Context, did string) (*GetBalanceResponse, error) {
+	var response GetBalanceResponse
+	status, err := c.do(ctx, http.MethodGet, endpointAccountInfo, "?did="+url.QueryEscape(did), nil, true, &response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	if !response.Status {
+		return nil, statusError(endpointAccountInfo, status, response.Message)
 	}
 
-	var response SignatureResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if len(response.AccountInfo) == 0 {
+		return nil, &APIError{Endpoint: endpointAccountInfo, StatusCode: status, Message: "no account info found for DID: " + did, Category: ErrNotFound}
+	}
+
+	return &response, nil
+}
+
+// GetAllDID retrieves all DIDs from the node
+func (c *Client) GetAllDID() (*GetAllDIDResponse, error) {
+	return c.GetAllDIDContext(context.Background())
+}
+
+// GetAllDIDContext is GetAllDID with a context
+func (c *Client) GetAllDIDContext(ctx context.Context) (*GetAllDIDResponse, error) {
+	var response GetAllDIDResponse
+	status, err := c.do(ctx, http.MethodGet, endpointAllDID, "", nil, true, &response)
+	if err != nil {
+		return nil, err
 	}
 
 	if !response.Status {
-		return nil, fmt.Errorf("signature submission failed: %s", response.Message)
+		return nil, statusError(endpointAllDID, status, response.Message)
 	}
 
 	return &response, nil
 }
 
-// GetBalance retrieves the account balance for a DID
-// Reference: /Users/allen/Professional/sky/lib/native_interaction/rubix/rubix_platform_calls.dart:231-261
-func (c *Client) GetBalance(did string) (*GetBalanceResponse, error) {
-	url := fmt.Sprintf("http://%s/api/get-account-info?did=%s", c.BaseURL, did)
+// GetTransactionsByDID retrieves the transactions a DID sent or received
+func (c *Client) GetTransactionsByDID(query TransactionQuery) (*GetTransactionsResponse, error) {
+	return c.GetTransactionsByDIDContext(context.Background(), query)
+}
+
+// GetTransactionsByDIDContext is GetTransactionsByDID with a context
+func (c *Client) GetTransactionsByDIDContext(ctx context.Context, query TransactionQuery) (*GetTransactionsResponse, error) {
+	params := url.Values{"DID": {query.DID}}
+	if query.Role != "" {
+		params.Set("Role", query.Role)
+	}
+	if !query.StartDate.IsZero() {
+		params.Set("StartDate", query.StartDate.Format(time.DateOnly))
+	}
+	if !query.EndDate.IsZero() {
+		params.Set("EndDate", query.EndDate.Format(time.DateOnly))
+	}
 
-	httpReq, err := http.NewRequest("GET", url, nil)
+	var response GetTransactionsResponse
+	status, err := c.do(ctx, http.MethodGet, endpointTxnByDID, "?"+params.Encode(), nil, true, &response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if !response.Status {
+		// Nodes answer status:false when a DID has no transactions in the range
+		if strings.Contains(strings.ToLower(response.Message), "no transaction") {
+			return &GetTransactionsResponse{Status: true, Message: response.Message}, nil
+		}
+		return nil, statusError(endpointTxnByDID, status, response.Message)
+	}
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	return &response, nil
+}
+
+// GetTransactionByID retrieves a finalized transaction by its transaction ID
+func (c *Client) GetTransactionByID(txnID string) (*GetTransactionsResponse, error) {
+	return c.GetTransactionByIDContext(context.Background(), txnID)
+}
+
+// GetTransactionByIDContext is GetTransactionByID with a context
+func (c *Client) GetTransactionByIDContext(ctx context.Context, txnID string) (*GetTransactionsResponse, error) {
+	var response GetTransactionsResponse
+	status, err := c.do(ctx, http.MethodGet, endpointTxnByID, "?txnID="+url.QueryEscape(txnID), nil, true, &response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	if !response.Status {
+		return nil, statusError(endpointTxnByID, status, response.Message)
 	}
 
-	var response GetBalanceResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	return &response, nil
+}
+
+// GetRequestStatus retrieves the state of a transfer request by the ID InitiateTransfer returned.
+// Request IDs are node-local: ask the node that initiated the transfer.
+func (c *Client) GetRequestStatus(requestID string) (*RequestStatusResponse, error) {
+	return c.GetRequestStatusContext(context.Background(), requestID)
+}
+
+// GetRequestStatusContext is GetRequestStatus with a context
+func (c *Client) GetRequestStatusContext(ctx context.Context, requestID string) (*RequestStatusResponse, error) {
+	var response RequestStatusResponse
+	status, err := c.do(ctx, http.MethodGet, endpointReqStatus, "?req_id="+url.QueryEscape(requestID), nil, true, &response)
+	if err != nil {
+		return nil, err
 	}
 
 	if !response.Status {
-		return nil, fmt.Errorf("get balance failed: %s", response.Message)
+		return nil, statusError(endpointReqStatus, status, response.Message)
 	}
 
-	if len(response.AccountInfo) == 0 {
-		return nil, fmt.Errorf("no account info found for DID: %s", did)
+	return &response, nil
+}
+
+// do sends a request to the node and decodes the JSON response into out,
+// retrying according to c.Retry. idempotent marks calls that are safe to repeat;
+// they fail over between c.Endpoints, trying every node at least once.
+// It returns the HTTP status of the decoded response; failures are *APIError.
+func (c *Client) do(ctx context.Context, method, endpoint, query string, in any, idempotent bool, out any) (int, error) {
+	var reqBody []byte
+	if in != nil {
+		var err error
+		reqBody, err = json.Marshal(in)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal request: %w", err)
+		}
 	}
 
-	return &response, nil
+	nodes := []string{c.BaseURL}
+	if idempotent {
+		nodes = c.nodes()
+	}
+	attempts := max(c.Retry.MaxRetries+1, len(nodes))
+
+	for attempt := 0; ; attempt++ {
+		node := nodes[attempt%len(nodes)]
+		status, body, retry, err := c.attempt(ctx, node, method, endpoint, query, reqBody, idempotent)
+		if err == nil {
+			c.nodeOK(node)
+			if err := json.Unmarshal(body, out); err != nil {
+				return status, &APIError{Endpoint: endpoint, StatusCode: status, Category: ErrMalformedResponse, Err: err}
+			}
+			return status, nil
+		}
+		if retry && len(nodes) > 1 {
+			c.nodeFailed(node)
+		}
+		if !retry || attempt+1 >= attempts {
+			return status, err
+		}
+
+		// Back off only once every node has been tried
+		if (attempt+1)%len(nodes) == 0 {
+			select {
+			case <-ctx.Done():
+				return status, err
+			case <-time.After(c.Retry.backoff((attempt + 1) / len(nodes))):
+			}
+		} else if ctx.Err() != nil {
+			return status, err
+		}
+	}
 }
 
-// GetAllDID retrieves all DIDs from the node
-func (c *Client) GetAllDID() (*GetAllDIDResponse, error) {
-	url := fmt.Sprintf("http://%s/api/getalldid", c.BaseURL)
+// attempt makes one request and reports whether a failure may be retried
+func (c *Client) attempt(ctx context.Context, node, method, endpoint, query string, reqBody []byte, idempotent bool) (int, []byte, bool, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
 
-	httpReq, err := http.NewRequest("GET", url, nil)
+	var bodyReader io.Reader
+	if reqBody != nil {
+		bodyReader = bytes.NewReader(reqBody)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, nodeURL(node, endpoint+query), bodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return 0, nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range c.Header {
+		httpReq.Header[name] = values
 	}
+	httpReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
 
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return 0, nil, idempotent || isDialError(err), transportError(endpoint, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return resp.StatusCode, nil, idempotent, transportError(endpoint, err)
 	}
 
-	var response GetAllDIDResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	overloaded := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	if (idempotent && overloaded) || (resp.StatusCode >= 400 && !json.Valid(body)) {
+		return resp.StatusCode, nil, idempotent && overloaded, httpError(endpoint, resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 
-	if !response.Status {
-		return nil, fmt.Errorf("get all DID failed: %s", response.Message)
-	}
+	return resp.StatusCode, body, false, nil
+}
 
-	return &response, nil
+// isDialError reports whether err happened before a connection was established,
+// so the node cannot have received the request
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
 }
@@ -0,0 +1,109 @@
+package rubix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Error categories of failed node calls. An *APIError matches its category
+// with errors.Is, e.g. errors.Is(err, rubix.ErrInsufficientBalance).
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrInvalidReceiver     = errors.New("invalid receiver")
+	ErrNotFound            = errors.New("not found")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrSignatureRejected   = errors.New("signature rejected")
+	ErrRejected            = errors.New("request rejected")
+	ErrNodeUnavailable     = errors.New("node unavailable")
+	ErrTimeout             = errors.New("timeout")
+	ErrNetwork             = errors.New("network error")
+	ErrMalformedResponse   = errors.New("malformed response")
+)
+
+// APIError describes a failed call to a node API endpoint
+type APIError struct {
+	Endpoint   string // API path, e.g. /api/initiate-rbt-transfer
+	StatusCode int    // HTTP status; 0 when no response was received
+	Message    string // the node's message, or the response body for non-JSON errors
+	Category   error  // one of the Err... categories
+	Err        error  // underlying network or decoding error, if any
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s failed (%v)", e.Endpoint, e.Category)
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.StatusCode != 0 && e.StatusCode != http.StatusOK {
+		fmt.Fprintf(&b, " (HTTP %d)", e.StatusCode)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+// Unwrap exposes both the category and the underlying error to errors.Is and errors.As
+func (e *APIError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Category}
+	}
+	return []error{e.Category, e.Err}
+}
+
+// transportError categorizes a failure to get a response
+func transportError(endpoint string, err error) *APIError {
+	category := ErrNetwork
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		category = ErrTimeout
+	}
+	return &APIError{Endpoint: endpoint, Category: category, Err: err}
+}
+
+// httpError categorizes an HTTP error response without a node message
+func httpError(endpoint string, statusCode int, body string) *APIError {
+	category := ErrRejected
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		category = ErrUnauthorized
+	case statusCode == http.StatusNotFound:
+		category = ErrNotFound
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		category = ErrNodeUnavailable
+	}
+	return &APIError{Endpoint: endpoint, StatusCode: statusCode, Message: body, Category: category}
+}
+
+// statusError categorizes a status:false answer from the node by its message
+func statusError(endpoint string, statusCode int, message string) *APIError {
+	if statusCode >= 400 {
+		e := httpError(endpoint, statusCode, message)
+		if e.Category != ErrRejected {
+			return e
+		}
+	}
+
+	msg := strings.ToLower(message)
+	category := ErrRejected
+	switch {
+	case strings.Contains(msg, "insufficient") || strings.Contains(msg, "not enough"):
+		category = ErrInsufficientBalance
+	case strings.Contains(msg, "receiver"):
+		category = ErrInvalidReceiver
+	case endpoint == endpointSignature && (strings.Contains(msg, "signature") || strings.Contains(msg, "verif")):
+		category = ErrSignatureRejected
+	case strings.Contains(msg, "does not exist") || strings.Contains(msg, "not found") ||
+		strings.Contains(msg, "no pending") || strings.Contains(msg, "no transaction") || strings.Contains(msg, "no request") ||
+		strings.Contains(msg, "failed to get account info"):
+		category = ErrNotFound
+	case strings.Contains(msg, "unauthori") || strings.Contains(msg, "forbidden"):
+		category = ErrUnauthorized
+	}
+	return &APIError{Endpoint: endpoint, StatusCode: statusCode, Message: message, Category: category}
+}
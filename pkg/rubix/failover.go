@@ -0,0 +1,163 @@
+package rubix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultNodeCooldown is how long a node that failed is skipped while others are healthy
+const DefaultNodeCooldown = 30 * time.Second
+
+// endpointNodeStatus answers while the node is running; used as health check
+const endpointNodeStatus = "/api/node-status"
+
+// healthCheckTimeout bounds a health check when Client.Timeout is longer or unset
+const healthCheckTimeout = 5 * time.Second
+
+// SplitNodeURLs splits a comma-separated list of equivalent node addresses
+func SplitNodeURLs(value string) []string {
+	var urls []string
+	for _, u := range strings.Split(value, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// nodePool tracks which of a client's nodes failed recently. It is shared by
+// the client and the copies returned by Pin and Pinned.
+type nodePool struct {
+	mu        sync.Mutex
+	next      int
+	downUntil map[string]time.Time
+}
+
+func newNodePool() *nodePool {
+	return &nodePool{downUntil: make(map[string]time.Time)}
+}
+
+// order returns the nodes starting at the next one in turn, so calls are
+// spread over the nodes. Nodes in their cooldown come last.
+func (p *nodePool) order(urls []string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	start := p.next % len(urls)
+	p.next++
+
+	now := time.Now()
+	healthy := make([]string, 0, len(urls))
+	var down []string
+	for i := range urls {
+		u := urls[(start+i)%len(urls)]
+		if now.Before(p.downUntil[u]) {
+			down = append(down, u)
+		} else {
+			healthy = append(healthy, u)
+		}
+	}
+	return append(healthy, down...)
+}
+
+func (p *nodePool) markDown(url string, cooldown time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.downUntil[url] = time.Now().Add(cooldown)
+}
+
+func (p *nodePool) markUp(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.downUntil, url)
+}
+
+// nodes returns the client's nodes in the order a read call should try them
+func (c *Client) nodes() []string {
+	if len(c.Endpoints) <= 1 || c.pool == nil {
+		return []string{c.BaseURL}
+	}
+	return c.pool.order(c.Endpoints)
+}
+
+// nodeFailed puts a node into its cooldown
+func (c *Client) nodeFailed(url string) {
+	if c.pool == nil {
+		return
+	}
+	cooldown := c.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultNodeCooldown
+	}
+	c.pool.markDown(url, cooldown)
+}
+
+// nodeOK ends a node's cooldown
+func (c *Client) nodeOK(url string) {
+	if c.pool != nil {
+		c.pool.markUp(url)
+	}
+}
+
+// Pinned returns a copy of the client that sends every call to url. Use it to
+// keep SubmitSignature on the node that initiated the transfer.
+func (c *Client) Pinned(url string) *Client {
+	pinned := *c
+	pinned.BaseURL = url
+	pinned.Endpoints = nil
+	return &pinned
+}
+
+// Pin picks a healthy node for a transfer and returns a client pinned to it.
+// Request IDs are node-local, so InitiateTransfer and SubmitSignature must go
+// to the same node. A client with a single node is returned unchanged.
+func (c *Client) Pin(ctx context.Context) (*Client, error) {
+	if len(c.Endpoints) <= 1 {
+		return c, nil
+	}
+
+	var lastErr error
+	for _, url := range c.nodes() {
+		if err := c.CheckNode(ctx, url); err != nil {
+			c.nodeFailed(url)
+			lastErr = err
+			continue
+		}
+		c.nodeOK(url)
+		return c.Pinned(url), nil
+	}
+	return nil, fmt.Errorf("none of %d nodes is healthy: %w", len(c.Endpoints), lastErr)
+}
+
+// CheckNode reports whether the node at url answers. Any response below
+// HTTP 500 counts as healthy, so nodes without the status endpoint still pass.
+func (c *Client) CheckNode(ctx context.Context, url string) error {
+	timeout := healthCheckTimeout
+	if c.Timeout > 0 && c.Timeout < timeout {
+		timeout = c.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nodeURL(url, endpointNodeStatus), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range c.Header {
+		req.Header[name] = values
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return transportError(endpointNodeStatus, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return httpError(endpointNodeStatus, resp.StatusCode, url)
+	}
+	return nil
+}
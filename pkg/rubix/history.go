@@ -0,0 +1,100 @@
+package rubix
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directions of a transaction as seen from the queried DID
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+	DirectionSelf     = "self"
+)
+
+// HistoryQuery selects a DID's transactions for TransactionHistory
+type HistoryQuery struct {
+	DID       string
+	Direction string    // DirectionSent, DirectionReceived or "" for both
+	From      time.Time // first day included; zero for no lower bound
+	To        time.Time // last day included; zero for no upper bound
+	Peer      string    // only transactions with this counterparty DID
+	Comment   string    // only transactions whose comment contains this text (case-insensitive)
+}
+
+// HistoryEntry is a transaction together with its direction for the queried DID
+type HistoryEntry struct {
+	TransactionDetails
+	Direction string `json:"Direction"`
+	Peer      string `json:"Peer"`
+}
+
+// TransactionHistory returns the transactions a DID sent and received, oldest first
+func (c *Client) TransactionHistory(ctx context.Context, q HistoryQuery) ([]HistoryEntry, error) {
+	var roles []string
+	switch q.Direction {
+	case DirectionSent:
+		roles = []string{RoleSender}
+	case DirectionReceived:
+		roles = []string{RoleReceiver}
+	default:
+		roles = []string{RoleSender, RoleReceiver}
+	}
+
+	seen := make(map[string]bool)
+	var entries []HistoryEntry
+	for _, role := range roles {
+		resp, err := c.GetTransactionsByDIDContext(ctx, TransactionQuery{DID: q.DID, Role: role, StartDate: q.From, EndDate: q.To})
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range resp.TxnDetails {
+			// Transfers to oneself come back for both roles
+			if seen[tx.TransactionID] {
+				continue
+			}
+			seen[tx.TransactionID] = true
+
+			entry := newHistoryEntry(q.DID, tx)
+			if q.matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].DateTime.Before(entries[j].DateTime) })
+	return entries, nil
+}
+
+func newHistoryEntry(did string, tx TransactionDetails) HistoryEntry {
+	entry := HistoryEntry{TransactionDetails: tx}
+	switch {
+	case tx.SenderDID == did && tx.ReceiverDID == did:
+		entry.Direction, entry.Peer = DirectionSelf, did
+	case tx.SenderDID == did:
+		entry.Direction, entry.Peer = DirectionSent, tx.ReceiverDID
+	default:
+		entry.Direction, entry.Peer = DirectionReceived, tx.SenderDID
+	}
+	return entry
+}
+
+// matches applies the filters the node does not: peer, comment and, for nodes
+// that ignore the date parameters, the date range
+func (q HistoryQuery) matches(e HistoryEntry) bool {
+	if q.Peer != "" && e.Peer != q.Peer {
+		return false
+	}
+	if q.Comment != "" && !strings.Contains(strings.ToLower(e.Comment), strings.ToLower(q.Comment)) {
+		return false
+	}
+	if !q.From.IsZero() && e.DateTime.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.DateTime.Before(q.To.AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
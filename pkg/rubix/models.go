@@ -1,5 +1,7 @@
 package rubix
 
+import "time"
+
 // InitiateTransferRequest represents the request to initiate a token transfer
 // Reference: /Users/allen/Professional/sky/lib/native_interaction/rubix/rubix_platform_calls.dart:121-127
 type InitiateTransferRequest struct {
@@ -62,6 +64,57 @@ type GetBalanceResponse struct {
 	AccountInfo []AccountInfo `json:"account_info"`
 }
 
+// Transaction roles of a DID in GetTransactionsByDID
+const (
+	RoleSender   = "Sender"
+	RoleReceiver = "Receiver"
+)
+
+// TransactionQuery selects the transactions of a DID. Dates are whole days
+// (YYYY-MM-DD on the wire); zero dates leave the range open.
+type TransactionQuery struct {
+	DID       string
+	Role      string // RoleSender or RoleReceiver
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// TransactionDetails is a transaction from the node's transaction history
+type TransactionDetails struct {
+	TransactionID   string    `json:"TransactionID"`
+	TransactionType string    `json:"TransactionType"`
+	BlockID         string    `json:"BlockID"`
+	Mode            int       `json:"Mode"`
+	SenderDID       string    `json:"SenderDID"`
+	ReceiverDID     string    `json:"ReceiverDID"`
+	Comment         string    `json:"Comment"`
+	DateTime        time.Time `json:"DateTime"`
+	Status          bool      `json:"Status"`
+	Amount          float64   `json:"Amount"`
+}
+
+// GetTransactionsResponse represents the response from the transactions-by-DID API
+type GetTransactionsResponse struct {
+	Status     bool                 `json:"status"`
+	Message    string               `json:"message"`
+	TxnDetails []TransactionDetails `json:"TxnDetails"`
+}
+
+// RequestStatus is the node's view of a transfer request
+type RequestStatus struct {
+	RequestID     string `json:"req_id"`
+	Status        string `json:"status"` // pending, success or failed
+	TransactionID string `json:"txn_id,omitempty"`
+	Message       string `json:"message,omitempty"`
+}
+
+// RequestStatusResponse represents the response from the request status API
+type RequestStatusResponse struct {
+	Status  bool          `json:"status"`
+	Message string        `json:"message"`
+	Result  RequestStatus `json:"result"`
+}
+
 // GetAllDIDResponse represents the response from get all DID API
 type GetAllDIDResponse struct {
 	Status      bool          `json:"status"`
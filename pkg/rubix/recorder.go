@@ -0,0 +1,250 @@
+package rubix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RecorderMode selects whether a Recorder captures or serves node interactions
+type RecorderMode int
+
+const (
+	// ReplayMode serves responses from the fixture file; no request reaches a node
+	ReplayMode RecorderMode = iota
+	// RecordMode forwards requests to the node and captures them for Save
+	RecordMode
+)
+
+// RecorderModeFromEnv returns RecordMode when RUBIX_RECORD is set, so tests
+// replay fixtures by default and re-record them against a live node on demand
+func RecorderModeFromEnv() RecorderMode {
+	if os.Getenv("RUBIX_RECORD") != "" {
+		return RecordMode
+	}
+	return ReplayMode
+}
+
+// Interaction is one recorded request and its response. JSON bodies are
+// stored as JSON so fixtures stay readable; other bodies are stored as text.
+type Interaction struct {
+	Method       string          `json:"method"`
+	Path         string          `json:"path"` // path and query, without the node address
+	Request      json.RawMessage `json:"request,omitempty"`
+	RequestText  string          `json:"request_text,omitempty"`
+	StatusCode   int             `json:"status_code"`
+	ContentType  string          `json:"content_type,omitempty"`
+	Response     json.RawMessage `json:"response,omitempty"`
+	ResponseText string          `json:"response_text,omitempty"`
+}
+
+// Fixture is the on-disk format of recorded interactions
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records node interactions to a
+// fixture file or replays them. Use it as the Client's transport:
+//
+//	rec, _ := rubix.NewRecorder("testdata/balance.json", rubix.RecorderModeFromEnv())
+//	client := rubix.NewClient(nodeURL)
+//	client.HTTPClient.Transport = rec
+//	...
+//	rec.Save() // writes the fixture in RecordMode
+//
+// Replay matches requests by method, path, query and body, in recorded order,
+// so the same call can be replayed with different responses.
+type Recorder struct {
+	Mode RecorderMode
+	Path string
+
+	// Transport performs real requests in RecordMode (default http.DefaultTransport)
+	Transport http.RoundTripper
+
+	mu      sync.Mutex
+	fixture Fixture
+	used    []bool
+}
+
+// NewRecorder creates a recorder. In ReplayMode the fixture file is loaded.
+func NewRecorder(path string, mode RecorderMode) (*Recorder, error) {
+	r := &Recorder{Mode: mode, Path: path}
+	if mode == RecordMode {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.fixture.Interactions))
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.Mode == RecordMode {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Method:      req.Method,
+		Path:        req.URL.RequestURI(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	in.Request, in.RequestText = encodeBody(body)
+	in.Response, in.ResponseText = encodeBody(respBody)
+
+	r.mu.Lock()
+	r.fixture.Interactions = append(r.fixture.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	path := req.URL.RequestURI()
+	want := normalizeBody(body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.fixture.Interactions {
+		if r.used[i] || in.Method != req.Method || in.Path != path {
+			continue
+		}
+		if normalizeBody(in.requestBody()) != want {
+			continue
+		}
+		r.used[i] = true
+
+		header := make(http.Header)
+		if in.ContentType != "" {
+			header.Set("Content-Type", in.ContentType)
+		}
+		respBody := in.responseBody()
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+			StatusCode:    in.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(respBody)),
+			ContentLength: int64(len(respBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction for %s %s in %s", req.Method, path, r.Path)
+}
+
+// Unused returns the recorded interactions that were not replayed
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, in := range r.fixture.Interactions {
+		if i < len(r.used) && !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the fixture file. It does nothing in ReplayMode.
+func (r *Recorder) Save() error {
+	if r.Mode != RecordMode {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.fixture, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(r.Path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+func (in Interaction) requestBody() []byte {
+	if len(in.Request) > 0 {
+		return in.Request
+	}
+	return []byte(in.RequestText)
+}
+
+func (in Interaction) responseBody() []byte {
+	if len(in.Response) > 0 {
+		return in.Response
+	}
+	return []byte(in.ResponseText)
+}
+
+// encodeBody stores JSON bodies as JSON and anything else as text
+func encodeBody(body []byte) (json.RawMessage, string) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, ""
+	}
+	if json.Valid(trimmed) {
+		var buf bytes.Buffer
+		json.Compact(&buf, trimmed)
+		return buf.Bytes(), ""
+	}
+	return nil, string(body)
+}
+
+// normalizeBody compacts JSON bodies so formatting differences do not prevent a match
+func normalizeBody(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	var buf bytes.Buffer
+	if json.Valid(trimmed) && json.Compact(&buf, trimmed) == nil {
+		return buf.String()
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,119 @@
+package rubix
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"break-nlss/pkg/crypto"
+)
+
+// ErrStatusUnknown is returned by RefreshResult when the node cannot tell
+// whether a request was already processed
+var ErrStatusUnknown = errors.New("transfer status unknown")
+
+// TransferKey is the default idempotency key of a transfer: a SHA3-256 digest
+// of what makes two transfers identical
+func TransferKey(senderDID, receiverDID string, amount float64, comment string) string {
+	fields := []string{
+		"break-nlss transfer",
+		senderDID,
+		receiverDID,
+		strconv.FormatFloat(amount, 'f', -1, 64),
+		comment,
+	}
+	return crypto.CalculateSHA3Hash(strings.Join(fields, "\n"))
+}
+
+// NewTransferID returns a random local transfer ID
+func NewTransferID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate transfer ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Resumable reports whether an interrupted transfer can be completed: the
+// node issued a request and hash, and its outcome is not known
+func (r *TransferResult) Resumable() bool {
+	switch r.State {
+	case TransferInitiated, TransferSigned, TransferPending:
+		return r.RequestID != "" && r.Hash != ""
+	}
+	return false
+}
+
+// RefreshResult asks the node whether the result's request was already
+// processed and, if so, records the answer in r. It reports false when the
+// node still waits for the signature, and an error matching ErrStatusUnknown
+// when the node cannot tell.
+func (c *Client) RefreshResult(ctx context.Context, r *TransferResult) (bool, error) {
+	st, err := c.TransferStatus(ctx, r.RequestID, r.TransactionID)
+	if err != nil {
+		return false, err
+	}
+	if st.Unknown {
+		return false, fmt.Errorf("%w: %s", ErrStatusUnknown, st.Message)
+	}
+	if st.TransactionID == "" && !st.Final() {
+		return false, nil
+	}
+	r.Update(st)
+	return true, nil
+}
+
+// ResumeTransfer completes a transfer interrupted after it was initiated, from
+// its journaled result. The node that initiated it is asked first whether the
+// signature was already processed, so it is never submitted twice. When the
+// node cannot tell, the transfer is only submitted with force. The journaled
+// signature is reused; otherwise params.Signer signs the hash.
+func ResumeTransfer(params TransferParams, result *TransferResult, force bool) (*TransferResult, error) {
+	if params.Signer != nil {
+		defer CloseSigner(params.Signer)
+	}
+	if !result.Resumable() {
+		return result, fmt.Errorf("transfer %s is %s and cannot be resumed", result.ID, result.State)
+	}
+
+	client := params.client()
+	if result.NodeURL != "" {
+		client = client.Pinned(result.NodeURL)
+	}
+	update := params.updater(result)
+
+	done, err := client.RefreshResult(context.Background(), result)
+	if errors.Is(err, ErrStatusUnknown) && force {
+		fmt.Printf("⚠ %v; submitting anyway\n", err)
+		done, err = false, nil
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to check request %s: %w", result.RequestID, err)
+	}
+	if !done {
+		return completeTransfer(params, client, result, params.Signer)
+	}
+
+	fmt.Printf("✓ Request %s was already processed by the node (%s)\n", result.RequestID, result.State)
+	update()
+	if result.State == TransferFailed {
+		return result, fmt.Errorf("%w: %s", ErrTransferFailed, result.Message)
+	}
+	if params.Wait > 0 && result.State != TransferConfirmed {
+		err := client.WaitForResult(result, params.Wait, params.PollInterval)
+		update()
+		return result, err
+	}
+	return result, nil
+}
+
+// Abandon marks an unfinished transfer as given up. The node keeps an
+// unsigned request until it expires, but it can no longer execute.
+func (r *TransferResult) Abandon(reason string) {
+	r.State, r.Message, r.UpdatedAt = TransferAbandoned, reason, time.Now()
+}
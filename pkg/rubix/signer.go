@@ -0,0 +1,269 @@
+package rubix
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/vault"
+)
+
+// Signer produces the signature payload submitted for a transaction hash.
+// Signers that hold secret material also implement io.Closer, which wipes it.
+type Signer interface {
+	Sign(hash string) (*SignatureData, error)
+}
+
+// Signer kinds that can be configured per DID
+const (
+	SignerNLSS     = "nlss"       // image signature only (Pixels)
+	SignerECDSA    = "ecdsa"      // ECDSA signature only (Signature)
+	SignerCombined = "nlss+ecdsa" // both, as BasicDID signs in rubixgoplatform
+)
+
+// ParseSignerKind validates a signer kind, defaulting to SignerNLSS
+func ParseSignerKind(kind string) (string, error) {
+	switch kind {
+	case "":
+		return SignerNLSS, nil
+	case SignerNLSS, SignerECDSA, SignerCombined:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown signer %q (use %s, %s or %s)", kind, SignerNLSS, SignerECDSA, SignerCombined)
+	}
+}
+
+// DID types as reported in AccountInfo.DIDType
+// Reference: rubixgoplatform did/model.go
+const (
+	DIDTypeBasic    = 0
+	DIDTypeStandard = 1
+	DIDTypeWallet   = 2
+	DIDTypeChild    = 3
+	DIDTypeLite     = 4
+)
+
+// DIDTypeName returns a readable name for a DID type
+func DIDTypeName(didType int) string {
+	switch didType {
+	case DIDTypeBasic:
+		return "basic"
+	case DIDTypeStandard:
+		return "standard"
+	case DIDTypeWallet:
+		return "wallet"
+	case DIDTypeChild:
+		return "child"
+	case DIDTypeLite:
+		return "lite"
+	default:
+		return fmt.Sprintf("unknown (%d)", didType)
+	}
+}
+
+// SignerKindForDIDType returns the signature payload a node expects for a DID type:
+// wallet DIDs send pixels only, lite DIDs the key signature only, and basic,
+// standard and child DIDs both
+func SignerKindForDIDType(didType int) (string, error) {
+	switch didType {
+	case DIDTypeWallet:
+		return SignerNLSS, nil
+	case DIDTypeLite:
+		return SignerECDSA, nil
+	case DIDTypeBasic, DIDTypeStandard, DIDTypeChild:
+		return SignerCombined, nil
+	default:
+		return "", fmt.Errorf("unsupported DID type %d", didType)
+	}
+}
+
+// ErrSignatureMismatch is returned when a signature fails local verification
+var ErrSignatureMismatch = errors.New("signature does not verify")
+
+// ShareSource names the private share a signer signs with, for error messages.
+// Wrappers that implement Unwrap() Signer are looked through.
+func ShareSource(s Signer) string {
+	if src, ok := s.(interface{ shareSource() string }); ok {
+		return src.shareSource()
+	}
+	if w, ok := s.(interface{ Unwrap() Signer }); ok {
+		return ShareSource(w.Unwrap())
+	}
+	return "private share"
+}
+
+// VerifyPixels checks an image signature against the DID image and public share,
+// so a broken private share is caught before the node rejects the signature.
+// source names the share that produced the signature.
+func VerifyPixels(didImagePath, pubSharePath, hash string, sig *SignatureData, source string) error {
+	for _, path := range []string{didImagePath, pubSharePath} {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("cannot verify signature locally: %w", err)
+		}
+	}
+
+	ok, err := nlss.NlssVerify(didImagePath, pubSharePath, hash, sig.Pixels)
+	if !ok {
+		return fmt.Errorf("%w: %s does not match %s and %s (%v)", ErrSignatureMismatch, source, didImagePath, pubSharePath, err)
+	}
+	return nil
+}
+
+// CloseSigner wipes the secret material held by a signer, if any
+func CloseSigner(s Signer) error {
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NLSSFileSigner signs with a private share stored as pvtShare.png
+type NLSSFileSigner struct {
+	PvtSharePath string
+}
+
+// Sign generates the image signature from the private share file
+func (s *NLSSFileSigner) Sign(hash string) (*SignatureData, error) {
+	pixels, err := crypto.Sign(s.PvtSharePath, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate image signature: %w", err)
+	}
+	return &SignatureData{Pixels: pixels}, nil
+}
+
+func (s *NLSSFileSigner) shareSource() string {
+	return s.PvtSharePath
+}
+
+// NLSSMemorySigner signs with private share pixel data held in memory.
+// Close wipes the share.
+type NLSSMemorySigner struct {
+	pvtShare []byte
+	source   string
+}
+
+// NewNLSSMemorySigner takes ownership of pvtShare; it is wiped on Close
+func NewNLSSMemorySigner(pvtShare []byte) *NLSSMemorySigner {
+	return &NLSSMemorySigner{pvtShare: pvtShare}
+}
+
+// NewReconstructedSigner rebuilds the private share from the DID image and
+// public share and keeps it in memory only
+func NewReconstructedSigner(didImagePath, pubSharePath string) (*NLSSMemorySigner, error) {
+	pvtShare, err := nlss.ReconstructFromFiles(didImagePath, pubSharePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconstruct private share: %w", err)
+	}
+	s := NewNLSSMemorySigner(pvtShare)
+	s.source = "private share reconstructed from " + pubSharePath
+	return s, nil
+}
+
+// NewVaultSigner decrypts the private share of a DID from the vault into memory
+func NewVaultSigner(v *vault.Vault, did string) (*NLSSMemorySigner, error) {
+	pvtShare, err := v.Load(did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private share: %w", err)
+	}
+	s := NewNLSSMemorySigner(pvtShare)
+	s.source = v.Path(did)
+	return s, nil
+}
+
+// Sign generates the image signature from the in-memory private share
+func (s *NLSSMemorySigner) Sign(hash string) (*SignatureData, error) {
+	if s.pvtShare == nil {
+		return nil, fmt.Errorf("private share already wiped")
+	}
+	pixels, err := nlss.SignShare(s.pvtShare, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate image signature: %w", err)
+	}
+	return &SignatureData{Pixels: pixels}, nil
+}
+
+func (s *NLSSMemorySigner) shareSource() string {
+	if s.source == "" {
+		return "in-memory private share"
+	}
+	return s.source
+}
+
+// Close wipes the private share
+func (s *NLSSMemorySigner) Close() error {
+	nlss.Wipe(s.pvtShare)
+	s.pvtShare = nil
+	return nil
+}
+
+// ECDSASigner signs with an EC private key (Lite DIDs sign the hash this way)
+type ECDSASigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewECDSASigner wraps an EC private key
+func NewECDSASigner(key *ecdsa.PrivateKey) *ECDSASigner {
+	return &ECDSASigner{key: key}
+}
+
+// NewECDSASignerFromPEM loads an EC private key from a PEM file,
+// optionally encrypted with password
+func NewECDSASignerFromPEM(keyFile, password string) (*ECDSASigner, error) {
+	key, err := crypto.LoadPrivateKeyFromPEMWithPassword(keyFile, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+	return NewECDSASigner(key), nil
+}
+
+// Sign generates the ECDSA signature over the hash
+func (s *ECDSASigner) Sign(hash string) (*SignatureData, error) {
+	sig, err := crypto.SignWithECDSA(s.key, []byte(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ECDSA signature: %w", err)
+	}
+	return &SignatureData{Signature: sig}, nil
+}
+
+// CombinedSigner produces the image signature and an ECDSA signature over it.
+// As in rubixgoplatform's BasicDID, the key signs the hex SHA3-256 of the
+// signature's bit string rather than the transaction hash.
+type CombinedSigner struct {
+	Image Signer
+	Key   *ECDSASigner
+}
+
+// Sign generates both signatures
+func (s *CombinedSigner) Sign(hash string) (*SignatureData, error) {
+	sig, err := s.Image.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	keySig, err := s.Key.Sign(PixelsDigest(sig.Pixels))
+	if err != nil {
+		return nil, err
+	}
+	sig.Signature = keySig.Signature
+	return sig, nil
+}
+
+// PixelsDigest returns what the key signs in a combined signature: the hex
+// SHA3-256 of the image signature's bit string
+func PixelsDigest(pixels []byte) string {
+	pvtPosStr := nlss.ConvertToBitString(pixels)
+	return crypto.HexToStr(crypto.CalculateHash([]byte(pvtPosStr), "SHA3-256"))
+}
+
+func (s *CombinedSigner) shareSource() string {
+	return ShareSource(s.Image)
+}
+
+// Close wipes the secret material of the image signer
+func (s *CombinedSigner) Close() error {
+	return CloseSigner(s.Image)
+}
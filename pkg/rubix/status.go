@@ -0,0 +1,260 @@
+package rubix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Transfer states, from initiation to finality
+const (
+	TransferInitiating = "initiating" // about to ask the node for a request; no request ID yet
+	TransferInitiated  = "initiated"  // the node issued a request ID and hash to sign
+	TransferSigned     = "signed"
+	TransferSubmitted  = "submitted" // the node accepted the signature; finality not checked
+	TransferPending    = "pending"   // not finalized yet
+	TransferConfirmed  = "confirmed"
+	TransferFailed     = "failed"
+	TransferAbandoned  = "abandoned" // given up locally before its signature was submitted
+)
+
+// DefaultPollInterval is the delay between status checks of WaitForTransfer
+const DefaultPollInterval = 2 * time.Second
+
+var (
+	// ErrTransferPending is returned when a transfer is not final before the wait ends
+	ErrTransferPending = errors.New("transfer not final yet")
+	// ErrTransferFailed is returned when a submitted transfer did not finalize
+	ErrTransferFailed = errors.New("transfer failed")
+)
+
+// TransferStatus is the state of a submitted transfer
+type TransferStatus struct {
+	RequestID     string              `json:"request_id,omitempty"`
+	TransactionID string              `json:"transaction_id,omitempty"`
+	State         string              `json:"state"`
+	Message       string              `json:"message,omitempty"`
+	Details       *TransactionDetails `json:"details,omitempty"` // the finalized transaction, once confirmed
+	Unknown       bool                `json:"unknown,omitempty"` // the node could not tell whether the request was processed
+}
+
+// Final reports whether the transfer is confirmed or failed
+func (s *TransferStatus) Final() bool {
+	return s.State == TransferConfirmed || s.State == TransferFailed
+}
+
+// trnxidPattern finds the transaction ID in the node's signature response,
+// e.g. "Transfer finished successfully in 1.2s with trnxid 4f1c..."
+var trnxidPattern = regexp.MustCompile(`trnxid\s+([0-9A-Za-z]+)`)
+
+// ParseTransactionID extracts the transaction ID from a SubmitSignature message
+func ParseTransactionID(message string) string {
+	if m := trnxidPattern.FindStringSubmatch(message); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// TransferStatus looks up a transfer by transaction ID, or by request ID when
+// the transaction ID is not known. Lookups by request ID must go to the node
+// that initiated the transfer. A node without status for the request (nodes
+// lacking the request status endpoint answer 404) is asked for a transaction
+// with the request ID instead; if it has none, the status is pending and Unknown.
+func (c *Client) TransferStatus(ctx context.Context, requestID, transactionID string) (*TransferStatus, error) {
+	st := &TransferStatus{RequestID: requestID, TransactionID: transactionID, State: TransferPending}
+
+	if st.TransactionID == "" {
+		if requestID == "" {
+			return nil, fmt.Errorf("a request ID or transaction ID is required")
+		}
+		resp, err := c.GetRequestStatusContext(ctx, requestID)
+		if errors.Is(err, ErrNotFound) {
+			return c.statusByRequestID(ctx, st, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		st.TransactionID, st.Message = resp.Result.TransactionID, resp.Result.Message
+		if resp.Result.Status == TransferFailed {
+			st.State = TransferFailed
+			return st, nil
+		}
+		if st.TransactionID == "" {
+			return st, nil
+		}
+	}
+
+	resp, err := c.GetTransactionByIDContext(ctx, st.TransactionID)
+	if errors.Is(err, ErrNotFound) {
+		// Not in a block yet
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.TxnDetails) == 0 {
+		return st, nil
+	}
+
+	details := resp.TxnDetails[0]
+	st.Details = &details
+	st.State = TransferFailed
+	if details.Status {
+		st.State = TransferConfirmed
+	}
+	return st, nil
+}
+
+// statusByRequestID looks the request ID up as a transaction ID, for nodes
+// that have no status for the request
+func (c *Client) statusByRequestID(ctx context.Context, st *TransferStatus, cause error) (*TransferStatus, error) {
+	found, err := c.TransferStatus(ctx, "", st.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	if found.Details != nil {
+		found.RequestID = st.RequestID
+		return found, nil
+	}
+	st.Unknown = true
+	st.Message = fmt.Sprintf("node has no status for request %s (%v)", st.RequestID, cause)
+	return st, nil
+}
+
+// WaitForTransfer polls TransferStatus every interval until the transfer is
+// confirmed or failed, or ctx ends. Network errors and unavailable nodes do not
+// stop the polling. When ctx ends first, the last status is returned with an
+// error matching ErrTransferPending.
+func (c *Client) WaitForTransfer(ctx context.Context, requestID, transactionID string, interval time.Duration) (*TransferStatus, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	last := &TransferStatus{RequestID: requestID, TransactionID: transactionID, State: TransferPending}
+	for {
+		st, err := c.TransferStatus(ctx, requestID, last.TransactionID)
+		switch {
+		case err == nil:
+			last = st
+			if st.Final() {
+				return st, nil
+			}
+		case errors.Is(err, ErrNetwork), errors.Is(err, ErrTimeout), errors.Is(err, ErrNodeUnavailable):
+			last.Message = err.Error()
+		default:
+			return last, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return last, fmt.Errorf("%w: %s is still %s: %v", ErrTransferPending, last.id(), last.State, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *TransferStatus) id() string {
+	if s.TransactionID != "" {
+		return "transaction " + s.TransactionID
+	}
+	return "request " + s.RequestID
+}
+
+// TransferResult records the outcome of a transfer for scripts
+type TransferResult struct {
+	ID             string `json:"id,omitempty"`              // local ID, set before the transfer is initiated
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // identifies identical transfers
+
+	RequestID     string    `json:"request_id"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	NodeURL       string    `json:"node_url,omitempty"`
+	SenderDID     string    `json:"sender_did"`
+	ReceiverDID   string    `json:"receiver_did"`
+	Amount        float64   `json:"amount"`
+	Comment       string    `json:"comment,omitempty"`
+	Hash          string    `json:"hash,omitempty"` // decoded hash that was signed
+	State         string    `json:"state"`
+	Message       string    `json:"message,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	Signature *SignatureData `json:"signature,omitempty"`
+}
+
+// Update copies a status lookup into the result
+func (r *TransferResult) Update(st *TransferStatus) {
+	r.State = st.State
+	if st.TransactionID != "" {
+		r.TransactionID = st.TransactionID
+	}
+	if st.Message != "" {
+		r.Message = st.Message
+	}
+	r.UpdatedAt = time.Now()
+}
+
+// RecordSubmission records the outcome of SubmitTransfer. When the node could
+// not be reached or answered too late, the transfer may still have been
+// processed, so it is left pending for a status lookup.
+func (r *TransferResult) RecordSubmission(resp *SignatureResponse, err error) {
+	r.UpdatedAt = time.Now()
+	if err != nil {
+		r.State = TransferFailed
+		if errors.Is(err, ErrNetwork) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrNodeUnavailable) {
+			r.State = TransferPending
+		}
+		r.Error = err.Error()
+		return
+	}
+	r.State, r.Error = TransferSubmitted, ""
+	r.Message = resp.Message
+	r.TransactionID = ParseTransactionID(resp.Message)
+}
+
+// SaveTransferResult writes a result as indented JSON
+func SaveTransferResult(path string, r *TransferResult) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transfer result: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write transfer result: %w", err)
+	}
+	return nil
+}
+
+// LoadTransferResult reads a result written by SaveTransferResult
+func LoadTransferResult(path string) (*TransferResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer result: %w", err)
+	}
+	var r TransferResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse transfer result: %w", err)
+	}
+	return &r, nil
+}
+
+// WaitForResult polls for up to timeout until the result's transfer is final
+// and records the outcome in r. It returns an error matching ErrTransferFailed
+// or ErrTransferPending unless the transfer was confirmed.
+func (c *Client) WaitForResult(r *TransferResult, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	st, err := c.WaitForTransfer(ctx, r.RequestID, r.TransactionID, interval)
+	r.Update(st)
+	if err == nil && st.State == TransferFailed {
+		err = fmt.Errorf("%w: %s", ErrTransferFailed, st.Message)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return err
+}
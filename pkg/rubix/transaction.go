@@ -1,10 +1,12 @@
 package rubix
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/base64"
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"break-nlss/pkg/crypto"
 )
@@ -17,13 +19,196 @@ type TransferParams struct {
 	Amount        float64
 	Comment       string
 	NLSSOutputDir string // Output directory where pvtShare.png files are stored
+
+	// Signer produces the signatures; defaults to an NLSSFileSigner reading
+	// {NLSSOutputDir}/{SenderDID}/pvtShare.png. Its secrets are wiped after submission.
+	Signer Signer
+
+	// DID image and public share used to verify the image signature locally
+	// before submission; verification is skipped when they are not set
+	DIDImagePath string
+	PubSharePath string
+
+	// Client talks to the node; defaults to NewClient(RubixNodeURL)
+	Client *Client
+
+	// Wait, if set, makes TransferTokens poll up to Wait for the transfer to
+	// be confirmed or failed, every PollInterval (default DefaultPollInterval)
+	Wait         time.Duration
+	PollInterval time.Duration
+
+	// IdempotencyKey identifies the transfer so an identical one is not made
+	// twice; defaults to TransferKey of the sender, receiver, amount and comment
+	IdempotencyKey string
+
+	// OnUpdate, if set, receives the result before each phase of TransferTokens
+	// and once it ends, e.g. to journal the transfer in a local ledger. An
+	// error before a phase stops the transfer before it reaches the node.
+	OnUpdate func(r *TransferResult) error
+}
+
+// client returns the configured client or a default one for RubixNodeURL
+func (p TransferParams) client() *Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return NewClient(p.RubixNodeURL)
+}
+
+// PendingTransfer is a transfer the node has initiated and is waiting to have signed
+type PendingTransfer struct {
+	RequestID string
+	Hash      string // decoded hash to sign
+	NodeURL   string // node that initiated the transfer; the signature must go there
 }
 
 // TransferTokens performs a complete two-phase token transfer
 // Phase 1: Initiate transfer and get hash
 // Phase 2: Sign hash and submit signatures
-func TransferTokens(params TransferParams) error {
-	client := NewClient(params.RubixNodeURL)
+// The result is returned, also with the error, once OnUpdate has seen it.
+// If OnUpdate fails before a phase, the transfer stops without starting it.
+func TransferTokens(params TransferParams) (*TransferResult, error) {
+	signer := params.Signer
+	if signer == nil {
+		// Construct path to private share: ./output/{sender_did}/pvtShare.png
+		pvtSharePath := filepath.Join(params.NLSSOutputDir, params.SenderDID, "pvtShare.png")
+		fmt.Printf("Signing with private share: %s\n", pvtSharePath)
+		signer = &NLSSFileSigner{PvtSharePath: pvtSharePath}
+	}
+	defer CloseSigner(signer)
+
+	result, err := NewTransferResult(params)
+	if err != nil {
+		return nil, err
+	}
+	update := params.updater(result)
+	if err := update(); err != nil {
+		return result, fmt.Errorf("transfer not initiated: %w", err)
+	}
+
+	pending, err := PrepareTransfer(params)
+	if err != nil {
+		// Without the hash the request cannot be signed, so it can never execute
+		result.State, result.Error, result.UpdatedAt = TransferFailed, err.Error(), time.Now()
+		update()
+		return result, err
+	}
+	result.RequestID, result.NodeURL, result.Hash = pending.RequestID, pending.NodeURL, pending.Hash
+	result.State, result.UpdatedAt = TransferInitiated, time.Now()
+	if err := update(); err != nil {
+		return result, fmt.Errorf("request %s not signed: %w", result.RequestID, err)
+	}
+
+	return completeTransfer(params, params.client().Pinned(pending.NodeURL), result, signer)
+}
+
+// NewTransferResult starts the record of a transfer that is about to be initiated
+func NewTransferResult(params TransferParams) (*TransferResult, error) {
+	id, err := NewTransferID()
+	if err != nil {
+		return nil, err
+	}
+	key := params.IdempotencyKey
+	if key == "" {
+		key = TransferKey(params.SenderDID, params.ReceiverDID, params.Amount, params.Comment)
+	}
+	now := time.Now()
+	return &TransferResult{
+		ID:             id,
+		IdempotencyKey: key,
+		SenderDID:      params.SenderDID,
+		ReceiverDID:    params.ReceiverDID,
+		Amount:         params.Amount,
+		Comment:        params.Comment,
+		State:          TransferInitiating,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, nil
+}
+
+// updater returns a function passing the result to OnUpdate, if set
+func (p TransferParams) updater(result *TransferResult) func() error {
+	return func() error {
+		if p.OnUpdate != nil {
+			return p.OnUpdate(result)
+		}
+		return nil
+	}
+}
+
+// completeTransfer signs an initiated transfer unless the result already holds
+// a signature, submits it to the node that initiated it and, if asked, waits
+// for finality
+func completeTransfer(params TransferParams, client *Client, result *TransferResult, signer Signer) (*TransferResult, error) {
+	update := params.updater(result)
+	fail := func(err error) (*TransferResult, error) {
+		result.State, result.Error, result.UpdatedAt = TransferFailed, err.Error(), time.Now()
+		update()
+		return result, err
+	}
+
+	sig := result.Signature
+	if sig == nil {
+		if signer == nil {
+			return nil, fmt.Errorf("a signer is required to sign request %s", result.RequestID)
+		}
+		var err error
+		if sig, err = SignTransfer(result.Hash, signer); err != nil {
+			return fail(err)
+		}
+
+		// Catch a broken private share before the node does
+		if len(sig.Pixels) > 0 && params.DIDImagePath != "" {
+			if err := VerifyPixels(params.DIDImagePath, params.PubSharePath, result.Hash, sig, ShareSource(signer)); err != nil {
+				return fail(err)
+			}
+			fmt.Println("✓ Image signature verified locally")
+		}
+
+		result.State, result.Signature, result.UpdatedAt = TransferSigned, sig, time.Now()
+		if err := update(); err != nil {
+			return result, fmt.Errorf("request %s not submitted: %w", result.RequestID, err)
+		}
+	}
+
+	signResp, err := SubmitTransfer(client, result.RequestID, sig)
+
+	// Secret material is no longer needed once the signature has been submitted
+	if signer != nil {
+		CloseSigner(signer)
+	}
+	result.RecordSubmission(signResp, err)
+	update()
+	if err != nil {
+		return result, err
+	}
+
+	fmt.Printf("\n✓ Transaction completed successfully!\n")
+	fmt.Printf("  Message: %s\n", signResp.Message)
+
+	if params.Wait > 0 {
+		fmt.Println("\nWaiting for the transfer to be finalized...")
+		err := client.WaitForResult(result, params.Wait, params.PollInterval)
+		update()
+		if err != nil {
+			return result, err
+		}
+		fmt.Printf("✓ Transfer confirmed (transaction %s)\n", result.TransactionID)
+	}
+
+	return result, nil
+}
+
+// PrepareTransfer runs phase 1: it initiates the transfer and decodes the hash to sign
+func PrepareTransfer(params TransferParams) (*PendingTransfer, error) {
+	nodes := params.client()
+	client, err := nodes.Pin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to select a Rubix node: %w", err)
+	}
+	if len(nodes.Endpoints) > 1 {
+		fmt.Printf("Using Rubix node: %s\n", client.BaseURL)
+	}
 
 	// ============================================
 	// PHASE 1: Initiate Transfer
@@ -40,7 +225,7 @@ func TransferTokens(params TransferParams) error {
 
 	initiateResp, err := client.InitiateTransfer(initiateReq)
 	if err != nil {
-		return fmt.Errorf("failed to initiate transfer: %w", err)
+		return nil, fmt.Errorf("failed to initiate transfer: %w", err)
 	}
 
 	requestID := initiateResp.Result.ID
@@ -50,54 +235,55 @@ func TransferTokens(params TransferParams) error {
 	fmt.Printf("  Request ID: %s\n", requestID)
 	fmt.Printf("  Hash (Base64): %s\n", hashBase64)
 
-	// ============================================
-	// PHASE 2: Sign and Complete
-	// ============================================
-	fmt.Println("\nPhase 2: Generating signatures...")
-
-	// 2.1: Decode hash from Base64
+	// Decode hash from Base64
 	hashBytes, err := base64.StdEncoding.DecodeString(hashBase64)
 	if err != nil {
-		return fmt.Errorf("failed to decode hash: %w", err)
+		return nil, fmt.Errorf("failed to decode hash: %w", err)
 	}
 	hash := string(hashBytes)
 	fmt.Printf("✓ Decoded hash: %s\n", hash)
 
-	// 2.2: Generate image-based signature
-	// Construct path to private share: ./output/{sender_did}/pvtShare.png
-	pvtSharePath := filepath.Join(params.NLSSOutputDir, params.SenderDID, "pvtShare.png")
-	fmt.Printf("  Generating image signature from: %s\n", pvtSharePath)
+	return &PendingTransfer{RequestID: requestID, Hash: hash, NodeURL: client.BaseURL}, nil
+}
+
+// SignTransfer runs phase 2: it signs the decoded hash. It needs no network access.
+func SignTransfer(hash string, signer Signer) (*SignatureData, error) {
+	// ============================================
+	// PHASE 2: Sign
+	// ============================================
+	fmt.Println("\nPhase 2: Generating signatures...")
 
-	imgSignBytes, err := crypto.Sign(pvtSharePath, hash)
+	sig, err := signer.Sign(hash)
 	if err != nil {
-		return fmt.Errorf("failed to generate image signature: %w", err)
+		return nil, err
+	}
+	if len(sig.Pixels) > 0 {
+		fmt.Printf("✓ Image signature generated (%d bytes)\n", len(sig.Pixels))
+	}
+	if len(sig.Signature) > 0 {
+		fmt.Printf("✓ ECDSA signature generated (%d bytes)\n", len(sig.Signature))
 	}
-	fmt.Printf("✓ Image signature generated (%d bytes)\n", len(imgSignBytes))
-	fmt.Println("The image sign here :", imgSignBytes)
 
-	// 2.3: Submit signatures
-	fmt.Println("\nPhase 3: Submitting signatures...")
+	return sig, nil
+}
 
-	// Use empty byte array for ECDSA signature (not required)
-	var pvtBytes []byte
+// SubmitTransfer runs phase 3: it submits the signatures for an initiated transfer
+func SubmitTransfer(client *Client, requestID string, sig *SignatureData) (*SignatureResponse, error) {
+	// ============================================
+	// PHASE 3: Submit and Complete
+	// ============================================
+	fmt.Println("\nPhase 3: Submitting signatures...")
 
 	signReq := SignatureRequest{
-		ID: requestID,
-		Signature: SignatureData{
-			Signature: pvtBytes,
-			Pixels:    imgSignBytes,
-		},
+		ID:        requestID,
+		Signature: *sig,
 	}
-	fmt.Println("The signReq :", signReq)
 	signResp, err := client.SubmitSignature(signReq)
 	if err != nil {
-		return fmt.Errorf("failed to submit signature: %w", err)
+		return nil, fmt.Errorf("failed to submit signature: %w", err)
 	}
 
-	fmt.Printf("\n✓ Transaction completed successfully!\n")
-	fmt.Printf("  Message: %s\n", signResp.Message)
-
-	return nil
+	return signResp, nil
 }
 
 // GetAccountBalance retrieves the balance for a DID
@@ -116,6 +302,20 @@ func GetAccountBalance(rubixNodeURL, did string) (float64, error) {
 	return response.AccountInfo[0].RBTAmount, nil
 }
 
+// GetDIDType retrieves the DID type of a DID from its node
+func GetDIDType(client *Client, did string) (int, error) {
+	response, err := client.GetBalance(did)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(response.AccountInfo) == 0 {
+		return 0, fmt.Errorf("no account info found")
+	}
+
+	return response.AccountInfo[0].DIDType, nil
+}
+
 // GenerateAndSaveKeys generates a new EC key pair and saves to files
 func GenerateAndSaveKeys(privateKeyPath, publicKeyPath string) (*ecdsa.PrivateKey, error) {
 	// Generate key pair
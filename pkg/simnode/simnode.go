@@ -0,0 +1,557 @@
+// Package simnode is a local stand-in for a Rubix node, serving the API calls
+// break-nlss makes against an in-memory ledger. Submitted signatures are checked
+// against the DID images registered for each account, so transfers, balances
+// and failures can be tested without a network.
+package simnode
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+// Account is a DID on the simulated node
+type Account struct {
+	DID        string  `json:"did"`
+	DIDType    int     `json:"did_type"`
+	Balance    float64 `json:"balance"`
+	Pledged    float64 `json:"pledged,omitempty"`
+	Locked     float64 `json:"locked,omitempty"`
+	Pinned     float64 `json:"pinned,omitempty"`
+	DIDImage   string  `json:"did_image,omitempty"` // needed to verify image signatures
+	PubShare   string  `json:"pub_share,omitempty"`
+	PubKeyPath string  `json:"pub_key,omitempty"` // needed to verify ECDSA signatures
+
+	PubKey *ecdsa.PublicKey `json:"-"`
+}
+
+// Transaction is a transfer processed by the node
+type Transaction struct {
+	ID          string    `json:"id"`
+	SenderDID   string    `json:"sender_did"`
+	ReceiverDID string    `json:"receiver_did"`
+	Amount      float64   `json:"amount"`
+	Comment     string    `json:"comment"`
+	Hash        string    `json:"hash"`
+	Status      string    `json:"status"` // pending, success or failed
+	Message     string    `json:"message,omitempty"`
+	Time        time.Time `json:"time"`
+	ConfirmedAt time.Time `json:"confirmed_at,omitempty"` // when a successful transfer becomes final
+}
+
+// confirmed reports whether a transfer succeeded and is final at now
+func (tx *Transaction) confirmed(now time.Time) bool {
+	return tx.Status == StatusSuccess && !now.Before(tx.ConfirmedAt)
+}
+
+// Transaction statuses
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// failure is a response injected with FailNext
+type failure struct {
+	statusCode int
+	message    string
+}
+
+// Node is a simulated Rubix node. The zero value is not usable; call New.
+type Node struct {
+	// Logf, if set, receives one line per handled request
+	Logf func(format string, args ...any)
+
+	// ConfirmDelay keeps successful transfers pending for this long after the
+	// signature was accepted, as a real node does until the block is final
+	ConfirmDelay time.Duration
+
+	mu           sync.Mutex
+	accounts     map[string]*Account
+	transactions []*Transaction
+	pending      map[string]*Transaction
+	failures     map[string][]failure
+}
+
+// New creates an empty node
+func New() *Node {
+	return &Node{
+		accounts: make(map[string]*Account),
+		pending:  make(map[string]*Transaction),
+		failures: make(map[string][]failure),
+	}
+}
+
+// AddAccount registers a DID, loading its public key from PubKeyPath if needed
+func (n *Node) AddAccount(a Account) error {
+	if a.DID == "" {
+		return fmt.Errorf("account without a DID")
+	}
+	if a.PubKey == nil && a.PubKeyPath != "" {
+		key, err := crypto.LoadPublicKeyFromPEM(a.PubKeyPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.DID, err)
+		}
+		a.PubKey = key
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.accounts[a.DID] = &a
+	return nil
+}
+
+// Account returns a copy of a registered account
+func (n *Node) Account(did string) (Account, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	a, ok := n.accounts[did]
+	if !ok {
+		return Account{}, false
+	}
+	return *a, true
+}
+
+// Transactions returns a copy of every transfer initiated on the node, oldest first
+func (n *Node) Transactions() []Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	txs := make([]Transaction, len(n.transactions))
+	for i, tx := range n.transactions {
+		txs[i] = *tx
+	}
+	return txs
+}
+
+// AddTransaction records a settled transfer in the node's history, e.g. to
+// seed older transactions. Balances are not changed.
+func (n *Node) AddTransaction(tx Transaction) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if tx.Status == "" {
+		tx.Status = StatusSuccess
+	}
+	if tx.Time.IsZero() {
+		tx.Time = time.Now()
+	}
+	n.transactions = append(n.transactions, &tx)
+}
+
+// FailNext makes the next request to path fail. A statusCode of 0 answers
+// 200 with status:false, as the node does for rejected requests.
+func (n *Node) FailNext(path string, statusCode int, message string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures[path] = append(n.failures[path], failure{statusCode: statusCode, message: message})
+}
+
+// Handler returns the node's HTTP API
+func (n *Node) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/initiate-rbt-transfer", n.handleInitiate)
+	mux.HandleFunc("POST /api/signature-response", n.handleSignature)
+	mux.HandleFunc("GET /api/get-account-info", n.handleAccountInfo)
+	mux.HandleFunc("GET /api/getalldid", n.handleAllDID)
+	mux.HandleFunc("GET /api/node-status", n.handleNodeStatus)
+	mux.HandleFunc("GET /api/get-by-did", n.handleTxnByDID)
+	mux.HandleFunc("GET /api/get-by-txnId", n.handleTxnByID)
+	mux.HandleFunc("GET /api/request-status", n.handleRequestStatus)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Logf != nil {
+			n.Logf("%s %s", r.Method, r.URL.Path)
+		}
+		if f, ok := n.takeFailure(r.URL.Path); ok {
+			if f.statusCode != 0 {
+				http.Error(w, f.message, f.statusCode)
+				return
+			}
+			writeJSON(w, map[string]any{"status": false, "message": f.message})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (n *Node) takeFailure(path string) (failure, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	queue := n.failures[path]
+	if len(queue) == 0 {
+		return failure{}, false
+	}
+	n.failures[path] = queue[1:]
+	return queue[0], true
+}
+
+func (n *Node) handleInitiate(w http.ResponseWriter, r *http.Request) {
+	var req rubix.InitiateTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, rubix.InitiateTransferResponse{Message: "invalid request: " + err.Error()})
+		return
+	}
+
+	tx, err := n.initiate(req)
+	if err != nil {
+		writeJSON(w, rubix.InitiateTransferResponse{Message: err.Error()})
+		return
+	}
+
+	var resp rubix.InitiateTransferResponse
+	resp.Status = true
+	resp.Message = "Signature needed"
+	resp.Result.ID = tx.ID
+	resp.Result.Hash = base64.StdEncoding.EncodeToString([]byte(tx.Hash))
+	writeJSON(w, resp)
+}
+
+// initiate checks a transfer request and locks the sender's tokens
+func (n *Node) initiate(req rubix.InitiateTransferRequest) (*Transaction, error) {
+	if req.TokenCount <= 0 {
+		return nil, fmt.Errorf("invalid amount: %v", req.TokenCount)
+	}
+	if req.Receiver == "" || req.Receiver == req.Sender {
+		return nil, fmt.Errorf("invalid receiver DID")
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	sender, ok := n.accounts[req.Sender]
+	if !ok {
+		return nil, fmt.Errorf("DID does not exist: %s", req.Sender)
+	}
+	if sender.Balance < req.TokenCount {
+		return nil, fmt.Errorf("insufficient balance: have %v RBT, need %v RBT", sender.Balance, req.TokenCount)
+	}
+	sender.Balance -= req.TokenCount
+	sender.Locked += req.TokenCount
+
+	fields := req.Sender + "\n" + req.Receiver + "\n" + strconv.FormatFloat(req.TokenCount, 'f', -1, 64) + "\n" + req.Comment + "\n" + id
+	tx := &Transaction{
+		ID:          id,
+		SenderDID:   req.Sender,
+		ReceiverDID: req.Receiver,
+		Amount:      req.TokenCount,
+		Comment:     req.Comment,
+		Hash:        crypto.CalculateSHA3Hash(fields),
+		Status:      StatusPending,
+		Time:        time.Now(),
+	}
+	n.transactions = append(n.transactions, tx)
+	n.pending[id] = tx
+	return tx, nil
+}
+
+func (n *Node) handleSignature(w http.ResponseWriter, r *http.Request) {
+	var req rubix.SignatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, rubix.SignatureResponse{Message: "invalid request: " + err.Error()})
+		return
+	}
+
+	if err := n.complete(req); err != nil {
+		writeJSON(w, rubix.SignatureResponse{Message: err.Error()})
+		return
+	}
+	writeJSON(w, rubix.SignatureResponse{Status: true, Message: "Transfer finished successfully in 1ms with trnxid " + req.ID})
+}
+
+// complete verifies the signatures for a pending transfer and settles it.
+// A transfer with bad signatures fails and its tokens are unlocked.
+func (n *Node) complete(req rubix.SignatureRequest) error {
+	n.mu.Lock()
+	tx, ok := n.pending[req.ID]
+	delete(n.pending, req.ID)
+	var sender Account
+	if ok {
+		sender = *n.accounts[tx.SenderDID]
+	}
+	n.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no pending transfer with ID %s", req.ID)
+	}
+
+	verifyErr := verifySignature(sender, tx.Hash, req.Signature)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s := n.accounts[tx.SenderDID]
+	s.Locked -= tx.Amount
+	if verifyErr != nil {
+		s.Balance += tx.Amount
+		tx.Status, tx.Message = StatusFailed, verifyErr.Error()
+		return verifyErr
+	}
+	if receiver, ok := n.accounts[tx.ReceiverDID]; ok {
+		receiver.Balance += tx.Amount
+	}
+	tx.Status = StatusSuccess
+	tx.ConfirmedAt = time.Now().Add(n.ConfirmDelay)
+	return nil
+}
+
+// verifySignature checks the signatures a DID's type requires
+func verifySignature(a Account, hash string, sig rubix.SignatureData) error {
+	kind, err := rubix.SignerKindForDIDType(a.DIDType)
+	if err != nil {
+		return err
+	}
+
+	if kind != rubix.SignerECDSA {
+		if a.DIDImage == "" || a.PubShare == "" {
+			return fmt.Errorf("no DID image registered for %s", a.DID)
+		}
+		ok, err := nlss.NlssVerify(a.DIDImage, a.PubShare, hash, sig.Pixels)
+		if !ok {
+			return fmt.Errorf("failed to verify image signature: %v", err)
+		}
+	}
+
+	if kind == rubix.SignerNLSS {
+		return nil
+	}
+	if a.PubKey == nil {
+		return fmt.Errorf("no public key registered for %s", a.DID)
+	}
+	data := []byte(hash)
+	if kind == rubix.SignerCombined {
+		data = []byte(rubix.PixelsDigest(sig.Pixels))
+	}
+	if ok, err := crypto.VerifyECDSASignature(a.PubKey, data, sig.Signature); err != nil || !ok {
+		return fmt.Errorf("failed to verify ECDSA signature")
+	}
+	return nil
+}
+
+func (n *Node) handleAccountInfo(w http.ResponseWriter, r *http.Request) {
+	did := r.URL.Query().Get("did")
+	a, ok := n.Account(did)
+	if !ok {
+		writeJSON(w, rubix.GetBalanceResponse{Message: "DID does not exist: " + did})
+		return
+	}
+	writeJSON(w, rubix.GetBalanceResponse{
+		Status:      true,
+		Message:     "Got account info successfully",
+		AccountInfo: []rubix.AccountInfo{a.info()},
+	})
+}
+
+func (n *Node) handleAllDID(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	infos := make([]rubix.AccountInfo, 0, len(n.accounts))
+	for _, a := range n.accounts {
+		infos = append(infos, a.info())
+	}
+	n.mu.Unlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].DID < infos[j].DID })
+
+	writeJSON(w, rubix.GetAllDIDResponse{Status: true, Message: "Got all DIDs", AccountInfo: infos})
+}
+
+// handleTxnByDID lists the successful transfers of a DID, filtered by role and
+// by an inclusive range of days
+func (n *Node) handleTxnByDID(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	did, role := q.Get("DID"), q.Get("Role")
+	if did == "" {
+		writeJSON(w, rubix.GetTransactionsResponse{Message: "DID is required"})
+		return
+	}
+
+	var start, end time.Time
+	for _, d := range []struct {
+		name string
+		t    *time.Time
+	}{{"StartDate", &start}, {"EndDate", &end}} {
+		if v := q.Get(d.name); v != "" {
+			t, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				writeJSON(w, rubix.GetTransactionsResponse{Message: "invalid " + d.name + ": " + v})
+				return
+			}
+			*d.t = t
+		}
+	}
+
+	n.mu.Lock()
+	now := time.Now()
+	details := []rubix.TransactionDetails{}
+	for _, tx := range n.transactions {
+		switch {
+		case !tx.confirmed(now):
+		case role == rubix.RoleSender && tx.SenderDID != did:
+		case role == rubix.RoleReceiver && tx.ReceiverDID != did:
+		case tx.SenderDID != did && tx.ReceiverDID != did:
+		case !start.IsZero() && tx.Time.Before(start):
+		case !end.IsZero() && !tx.Time.Before(end.AddDate(0, 0, 1)):
+		default:
+			details = append(details, tx.details())
+		}
+	}
+	n.mu.Unlock()
+
+	writeJSON(w, rubix.GetTransactionsResponse{Status: true, Message: "Retrieved Txn Details", TxnDetails: details})
+}
+
+// handleTxnByID finds a final transaction; pending ones are not known yet
+func (n *Node) handleTxnByID(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("txnID")
+	tx, ok := n.transaction(id)
+	if !ok || (tx.Status != StatusFailed && !tx.confirmed(time.Now())) {
+		writeJSON(w, rubix.GetTransactionsResponse{Message: "No transaction found for txnID " + id})
+		return
+	}
+	writeJSON(w, rubix.GetTransactionsResponse{Status: true, Message: "Retrieved Txn Details", TxnDetails: []rubix.TransactionDetails{tx.details()}})
+}
+
+// handleRequestStatus reports the state of a transfer request
+func (n *Node) handleRequestStatus(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("req_id")
+	tx, ok := n.transaction(id)
+	if !ok {
+		writeJSON(w, rubix.RequestStatusResponse{Message: "no request with ID " + id})
+		return
+	}
+
+	result := rubix.RequestStatus{RequestID: tx.ID, Status: tx.Status, Message: tx.Message}
+	if tx.Status == StatusSuccess {
+		result.TransactionID = tx.ID
+	}
+	writeJSON(w, rubix.RequestStatusResponse{Status: true, Message: "Request status", Result: result})
+}
+
+// transaction returns a copy of a transfer by its ID
+func (n *Node) transaction(id string) (Transaction, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, tx := range n.transactions {
+		if tx.ID == id {
+			return *tx, true
+		}
+	}
+	return Transaction{}, false
+}
+
+func (tx *Transaction) details() rubix.TransactionDetails {
+	return rubix.TransactionDetails{
+		TransactionID:   tx.ID,
+		TransactionType: "02",
+		SenderDID:       tx.SenderDID,
+		ReceiverDID:     tx.ReceiverDID,
+		Comment:         tx.Comment,
+		DateTime:        tx.Time,
+		Status:          tx.Status == StatusSuccess,
+		Amount:          tx.Amount,
+	}
+}
+
+func (n *Node) handleNodeStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{"status": true, "message": "Node is up and running"})
+}
+
+func (a *Account) info() rubix.AccountInfo {
+	return rubix.AccountInfo{
+		DID:        a.DID,
+		DIDType:    a.DIDType,
+		RBTAmount:  a.Balance,
+		PledgedRBT: a.Pledged,
+		LockedRBT:  a.Locked,
+		PinnedRBT:  a.Pinned,
+	}
+}
+
+// Server is a node listening on a local address
+type Server struct {
+	Node *Node
+	Addr string // host:port, usable as RUBIX_NODE_URL
+
+	srv *http.Server
+}
+
+// Start serves a node in the background. An empty addr picks a free port on 127.0.0.1.
+func Start(n *Node, addr string) (*Server, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	s := &Server{Node: n, Addr: ln.Addr().String(), srv: &http.Server{Handler: n.Handler()}}
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// accountsFile is the on-disk accounts format
+type accountsFile struct {
+	Accounts []Account `json:"accounts"`
+}
+
+// LoadAccounts reads an accounts file. Relative paths are resolved against
+// the file's directory.
+func LoadAccounts(path string) ([]Account, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts file: %w", err)
+	}
+
+	var file accountsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse accounts file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	for i := range file.Accounts {
+		a := &file.Accounts[i]
+		a.DIDImage, a.PubShare, a.PubKeyPath = resolve(a.DIDImage), resolve(a.PubShare), resolve(a.PubKeyPath)
+	}
+	return file.Accounts, nil
+}
+
+func randomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate request ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
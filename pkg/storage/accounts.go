@@ -16,6 +16,14 @@ type DIDAccount struct {
 	LockedRBT  float64   `json:"locked_rbt"`
 	PinnedRBT  float64   `json:"pinned_rbt"`
 	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Set when exported from several nodes at once
+	NodeName     string `json:"node_name,omitempty"`
+	RubixNodeURL string `json:"rubix_node_url,omitempty"`
+
+	// Optional signing settings for transfers from this DID
+	Signer  string `json:"signer,omitempty"`   // nlss, ecdsa or nlss+ecdsa
+	KeyFile string `json:"key_file,omitempty"` // EC private key PEM for ecdsa signing
 }
 
 // AccountsFile represents the structure of the accounts file
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LedgerEntry is one line of the transfer ledger: the full state of a
+// transfer before each of its phases and once it ends. A transfer's latest
+// entry is its receipt.
+type LedgerEntry struct {
+	ID             string `json:"id,omitempty"` // local transfer ID; entries written before it existed use RequestID
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	RequestID     string    `json:"request_id"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	SenderDID     string    `json:"sender_did"`
+	ReceiverDID   string    `json:"receiver_did"`
+	Amount        float64   `json:"amount"`
+	Comment       string    `json:"comment,omitempty"`
+	Hash          string    `json:"hash,omitempty"`
+	Signature     []byte    `json:"signature,omitempty"` // ECDSA signature
+	Pixels        []byte    `json:"pixels,omitempty"`    // image signature
+	NodeURL       string    `json:"node_url,omitempty"`
+	State         string    `json:"state"` // initiating, initiated, signed, submitted, pending, confirmed, failed or abandoned
+	Message       string    `json:"message,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"` // when the transfer was initiated
+	Timestamp     time.Time `json:"timestamp"`  // when this entry was written
+}
+
+// Ledger appends transfer entries to a JSON lines file. Entries are never
+// rewritten; every entry is synced to disk before Append returns.
+type Ledger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenLedger opens a ledger file for appending, creating it and its directory if needed
+func OpenLedger(path string) (*Ledger, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create ledger directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	return &Ledger{file: file}, nil
+}
+
+// Append writes one entry to the ledger
+func (l *Ledger) Append(entry LedgerEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write ledger entry: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// Close closes the ledger file
+func (l *Ledger) Close() error {
+	return l.file.Close()
+}
+
+// LoadLedger reads every entry of a ledger file in the order written. A missing
+// file yields no entries. A truncated last line (from a process that died
+// mid-write) is ignored.
+func LoadLedger(path string) ([]LedgerEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+
+	var entries []LedgerEntry
+	var pendingErr error
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// Only the final line may be malformed
+		if pendingErr != nil {
+			return nil, pendingErr
+		}
+
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			pendingErr = fmt.Errorf("invalid ledger entry on line %d: %w", lineNum, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ledger: %w", err)
+	}
+
+	return entries, nil
+}
+
+// TransferID identifies the transfer an entry belongs to
+func (e LedgerEntry) TransferID() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return e.RequestID
+}
+
+// Unfinished reports whether the transfer stopped before its outcome was
+// known: it may still hold tokens locked on the node, or have been executed
+func (e LedgerEntry) Unfinished() bool {
+	switch e.State {
+	case "initiating", "initiated", "signed", "pending":
+		return true
+	}
+	return false
+}
+
+// Receipts returns the latest entry of each transfer, oldest transfer first
+func Receipts(entries []LedgerEntry) []LedgerEntry {
+	latest := make(map[string]int)
+	var receipts []LedgerEntry
+	for _, e := range entries {
+		if i, ok := latest[e.TransferID()]; ok {
+			receipts[i] = e
+			continue
+		}
+		latest[e.TransferID()] = len(receipts)
+		receipts = append(receipts, e)
+	}
+
+	sort.SliceStable(receipts, func(i, j int) bool { return receipts[i].CreatedAt.Before(receipts[j].CreatedAt) })
+	return receipts
+}
+
+// Trail returns every entry of the receipt's transfer, in the order written
+func Trail(entries []LedgerEntry, receipt LedgerEntry) []LedgerEntry {
+	var trail []LedgerEntry
+	for _, e := range entries {
+		if e.TransferID() == receipt.TransferID() {
+			trail = append(trail, e)
+		}
+	}
+	return trail
+}
+
+// FindDuplicates returns the receipts of transfers with the idempotency key
+// that may have moved tokens: unfinished ones, and those created after since
+// that did not fail and were not abandoned
+func FindDuplicates(receipts []LedgerEntry, key string, since time.Time) []LedgerEntry {
+	var dups []LedgerEntry
+	for _, r := range receipts {
+		if r.IdempotencyKey != key || r.State == "failed" || r.State == "abandoned" {
+			continue
+		}
+		if r.Unfinished() || !r.CreatedAt.Before(since) {
+			dups = append(dups, r)
+		}
+	}
+	return dups
+}
+
+// ReceiptQuery selects receipts for FilterReceipts. Empty fields match everything.
+type ReceiptQuery struct {
+	DID           string // sender or receiver
+	ID            string // transfer ID or request ID
+	RequestID     string
+	TransactionID string
+	State         string
+	From          time.Time // first day included, by CreatedAt
+	To            time.Time // last day included, by CreatedAt
+}
+
+// FilterReceipts returns the receipts matching q
+func FilterReceipts(receipts []LedgerEntry, q ReceiptQuery) []LedgerEntry {
+	var matched []LedgerEntry
+	for _, r := range receipts {
+		if q.DID != "" && r.SenderDID != q.DID && r.ReceiverDID != q.DID {
+			continue
+		}
+		if q.ID != "" && r.TransferID() != q.ID && r.RequestID != q.ID {
+			continue
+		}
+		if (q.RequestID != "" && r.RequestID != q.RequestID) || (q.TransactionID != "" && r.TransactionID != q.TransactionID) {
+			continue
+		}
+		if q.State != "" && r.State != q.State {
+			continue
+		}
+		if !q.From.IsZero() && r.CreatedAt.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !r.CreatedAt.Before(q.To.AddDate(0, 0, 1)) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	return matched
+}
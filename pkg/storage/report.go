@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Break-NLSS report statuses
+const (
+	BreakStatusSuccess = "success"
+	BreakStatusFailed  = "failed"
+)
+
+// BreakReportRecord is one line of a break-nlss report file
+type BreakReportRecord struct {
+	DID        string    `json:"did"`
+	Node       string    `json:"node,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	OutputPath string    `json:"output_path,omitempty"`
+	Verified   bool      `json:"verified"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Completed reports whether the record marks a verified, finished reconstruction
+func (r BreakReportRecord) Completed() bool {
+	return r.Status == BreakStatusSuccess && r.Verified
+}
+
+// BreakReport appends records to a JSON lines report file.
+// Every record is synced to disk so the report doubles as a checkpoint.
+type BreakReport struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenBreakReport opens a report file for appending, creating it if needed
+func OpenBreakReport(filepath string) (*BreakReport, error) {
+	file, err := os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open report file: %w", err)
+	}
+	return &BreakReport{file: file}, nil
+}
+
+// Append writes one record to the report
+func (r *BreakReport) Append(record BreakReportRecord) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report record: %w", err)
+	}
+	data = append(data, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write report record: %w", err)
+	}
+	return r.file.Sync()
+}
+
+// Close closes the report file
+func (r *BreakReport) Close() error {
+	return r.file.Close()
+}
+
+// LoadBreakReport reads a report file and returns the latest record for each DID.
+// A missing file yields an empty map. A truncated last line (from a run that
+// died mid-write) is ignored.
+func LoadBreakReport(filepath string) (map[string]BreakReportRecord, error) {
+	records := make(map[string]BreakReportRecord)
+
+	file, err := os.Open(filepath)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open report file: %w", err)
+	}
+	defer file.Close()
+
+	var pendingErr error
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// Only the final line may be malformed
+		if pendingErr != nil {
+			return nil, pendingErr
+		}
+
+		var record BreakReportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			pendingErr = fmt.Errorf("invalid report record on line %d: %w", lineNum, err)
+			continue
+		}
+		records[record.DID] = record
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading report file: %w", err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,155 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"break-nlss/pkg/crypto"
+)
+
+// FileName is the encrypted private share inside a DID folder of the vault
+const FileName = "pvtShare.vault"
+
+// checkFileName holds a sealed marker used to reject a wrong password early
+const checkFileName = "vault.check"
+
+var checkMarker = []byte("break-nlss private share vault v1")
+
+// ErrWrongPassword is returned when a password or key file does not open an existing vault
+var ErrWrongPassword = errors.New("vault password does not match")
+
+// ErrNotFound is returned when the vault holds no private share for a DID
+var ErrNotFound = errors.New("private share not found in vault")
+
+// Vault stores private shares encrypted at rest with the Seal/UnSeal scheme.
+// Layout: {dir}/{did}/pvtShare.vault, directories 0700 and files 0600.
+type Vault struct {
+	dir      string
+	password string
+}
+
+// Open opens the vault in dir with a password, creating it if needed.
+// A vault is bound to the first password used with it.
+func Open(dir, password string) (*Vault, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("vault directory not configured")
+	}
+	if password == "" {
+		return nil, fmt.Errorf("vault password is empty")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create vault directory: %w", err)
+	}
+
+	v := &Vault{dir: dir, password: password}
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OpenWithKeyFile opens the vault in dir using the contents of keyFile as the password
+func OpenWithKeyFile(dir, keyFile string) (*Vault, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault key file: %w", err)
+	}
+	return Open(dir, strings.TrimSpace(string(data)))
+}
+
+// check verifies the password against the vault marker, writing it for a new vault
+func (v *Vault) check() error {
+	checkPath := filepath.Join(v.dir, checkFileName)
+
+	sealed, err := os.ReadFile(checkPath)
+	if os.IsNotExist(err) {
+		sealed, err = crypto.Seal(v.password, checkMarker)
+		if err != nil {
+			return fmt.Errorf("failed to seal vault marker: %w", err)
+		}
+		return writeFile(checkPath, sealed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read vault marker: %w", err)
+	}
+
+	marker, err := crypto.UnSeal(v.password, sealed)
+	if err != nil || !bytes.Equal(marker, checkMarker) {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// Dir returns the vault directory
+func (v *Vault) Dir() string {
+	return v.dir
+}
+
+// Path returns the location of the encrypted private share for a DID
+func (v *Vault) Path(did string) string {
+	return filepath.Join(v.dir, did, FileName)
+}
+
+// Exists reports whether the vault holds a private share for a DID
+func (v *Vault) Exists(did string) bool {
+	_, err := os.Stat(v.Path(did))
+	return err == nil
+}
+
+// Store encrypts private share pixel data and writes it for a DID
+func (v *Vault) Store(did string, pvtBytes []byte) error {
+	if err := os.MkdirAll(filepath.Join(v.dir, did), 0700); err != nil {
+		return fmt.Errorf("failed to create vault directory: %w", err)
+	}
+
+	sealed, err := crypto.Seal(v.password, pvtBytes)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt private share: %w", err)
+	}
+	return writeFile(v.Path(did), sealed)
+}
+
+// Load decrypts the private share pixel data for a DID.
+// Callers should wipe the result once they are done with it.
+func (v *Vault) Load(did string) ([]byte, error) {
+	sealed, err := os.ReadFile(v.Path(did))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, did)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault entry: %w", err)
+	}
+
+	pvtBytes, err := crypto.UnSeal(v.password, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private share: %w", err)
+	}
+	return pvtBytes, nil
+}
+
+// writeFile writes data with 0600 permissions via a temporary file,
+// so a crash never leaves a truncated entry behind
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create vault file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set vault file permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
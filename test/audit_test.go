@@ -0,0 +1,126 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"break-nlss/pkg/audit"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestAuditLogChain(t *testing.T) {
+	shares := generateTestShares(t, "audit")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "audit", "audit.jsonl")
+	log, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	pvtSharePath := filepath.Join(dir, nlss.PvtShareFileName)
+	signer := &audit.Signer{
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: pvtSharePath},
+		Log:    log,
+		Entry:  audit.Entry{Command: "test", DID: "alice", Signer: rubix.SignerNLSS},
+	}
+	hash := nlss.CalculateSHA3Hash("audited transfer")
+	sig, err := signer.Sign(hash)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if got := rubix.ShareSource(signer); got != pvtSharePath {
+		t.Errorf("ShareSource through the audit signer = %q; want %q", got, pvtSharePath)
+	}
+	if err := log.Append(audit.Entry{Operation: audit.OpReconstruct, Command: "break-nlss", DID: "bob", Output: "out/pvtShare.png"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// A log reopened later continues the chain
+	if log, err = audit.Open(path); err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	if _, err := (&audit.Signer{Signer: signer.Signer, Log: log}).Sign(hash); err != nil {
+		t.Fatalf("Sign after reopening failed: %v", err)
+	}
+
+	report, err := audit.Verify(path)
+	if err != nil || report.Entries != 3 {
+		t.Fatalf("Verify = %+v, %v; want 3 intact entries", report, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if !bytes.Contains(lines[0], []byte(audit.SignatureDigest(sig))) || !bytes.Contains(lines[0], []byte(hash)) {
+		t.Errorf("First entry %s does not record the hash and signature digest", lines[0])
+	}
+
+	tamper := func(name string, content []byte) {
+		t.Helper()
+		if err := os.WriteFile(path, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := audit.Verify(path); !errors.Is(err, audit.ErrTampered) {
+			t.Errorf("Verify after %s = %v; want ErrTampered", name, err)
+		}
+	}
+	edited := bytes.Replace(data, []byte(`"did":"bob"`), []byte(`"did":"eve"`), 1)
+	tamper("editing an entry", edited)
+	tamper("deleting an entry", bytes.Join([][]byte{lines[0], lines[2]}, nil))
+	tamper("deleting the last entry", bytes.Join(lines[:2], nil))
+	lastEdited := bytes.Replace(lines[2], []byte(`"operation":"sign"`), []byte(`"operation":"none"`), 1)
+	tamper("editing the last entry", bytes.Join([][]byte{lines[0], lines[1], lastEdited}, nil))
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := audit.Verify(path); err != nil {
+		t.Errorf("Verify of the restored log = %v", err)
+	}
+}
+
+func TestAuditLogConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	// Two logs opened before either appends, as two CLI processes would
+	var logs []*audit.Log
+	for range 2 {
+		log, err := audit.Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		logs = append(logs, log)
+	}
+
+	const perWriter = 20
+	var wg sync.WaitGroup
+	for i, log := range logs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWriter {
+				if err := log.Append(audit.Entry{Operation: audit.OpSign, Command: "writer " + strconv.Itoa(i)}); err != nil {
+					t.Errorf("Append failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	report, err := audit.Verify(path)
+	if err != nil || report.Entries != 2*perWriter {
+		t.Errorf("Verify = %+v, %v; want %d intact entries", report, err, 2*perWriter)
+	}
+}
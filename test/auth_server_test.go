@@ -0,0 +1,194 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"break-nlss/pkg/auth"
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestAuthServerLogin(t *testing.T) {
+	shares := generateTestShares(t, "auth-server-test")
+	dir := t.TempDir()
+	didDir := filepath.Join(dir, "alice")
+	if err := nlss.SaveShares(shares, didDir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+	signer := &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(didDir, nlss.PvtShareFileName)}
+
+	registryPath := filepath.Join(dir, "registry.json")
+	key, err := crypto.GenerateECKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.SavePublicKeyToPEM(&key.PublicKey, filepath.Join(didDir, "pubKey.pem")); err != nil {
+		t.Fatal(err)
+	}
+	registry := `{"dids": [
+		{"did": "alice", "did_image": "alice/did.png", "pub_share": "alice/pubShare.png", "allow_nlss_only": true},
+		{"did": "carol", "did_image": "alice/did.png", "pub_share": "alice/pubShare.png", "pub_key": "alice/pubKey.pem"}
+	]}`
+	if err := os.WriteFile(registryPath, []byte(registry), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := auth.LoadRegistry(registryPath)
+	if err != nil {
+		t.Fatalf("LoadRegistry failed: %v", err)
+	}
+
+	// Without a key, NLSS-only logins must be allowed explicitly
+	unkeyedPath := filepath.Join(dir, "unkeyed.json")
+	if err := os.WriteFile(unkeyedPath, []byte(`{"dids": [{"did": "alice", "did_image": "alice/did.png", "pub_share": "alice/pubShare.png"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.LoadRegistry(unkeyedPath); err == nil || !strings.Contains(err.Error(), "allow_nlss_only") {
+		t.Errorf("LoadRegistry without pub_key = %v; want an error pointing to allow_nlss_only", err)
+	}
+
+	srv, err := auth.NewServer(reg, []byte("test secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	srv.SetClock(func() time.Time { return now })
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	post := func(path string, body any) (int, map[string]any) {
+		data, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	signChallengeWith := func(did string, signer rubix.Signer) *auth.SignedMessage {
+		status, out := post("/auth/challenge", map[string]string{"did": did})
+		if status != http.StatusOK {
+			t.Fatalf("challenge for %s: status %d (%v)", did, status, out["error"])
+		}
+		signed, err := auth.SignMessage(did, []byte(out["challenge"].(string)), signer, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	signChallenge := func(did string) *auth.SignedMessage {
+		return signChallengeWith(did, signer)
+	}
+
+	// Unregistered DIDs get no challenge
+	if status, _ := post("/auth/challenge", map[string]string{"did": "mallory"}); status != http.StatusForbidden {
+		t.Errorf("challenge for unregistered DID: status %d; want 403", status)
+	}
+
+	// Oversized bodies are refused before they are decoded
+	padded := map[string]string{"did": "alice", "padding": strings.Repeat("x", 64<<10)}
+	for _, path := range []string{"/auth/challenge", "/auth/login"} {
+		if status, _ := post(path, padded); status != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s with a 64 KB body: status %d; want 413", path, status)
+		}
+	}
+
+	// Login and check the session
+	signed := signChallenge("alice")
+	status, out := post("/auth/login", signed)
+	if status != http.StatusOK {
+		t.Fatalf("login: status %d (%v)", status, out["error"])
+	}
+	token := out["token"].(string)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/auth/session", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session auth.Session
+	json.NewDecoder(resp.Body).Decode(&session)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || session.DID != "alice" {
+		t.Errorf("session: status %d, DID %q; want 200 and alice", resp.StatusCode, session.DID)
+	}
+
+	// Challenges are single use
+	if status, _ := post("/auth/login", signed); status != http.StatusUnauthorized {
+		t.Errorf("replayed login: status %d; want 401", status)
+	}
+
+	// A signature over another message does not log in
+	forged := signChallenge("alice")
+	other, _ := auth.SignMessage("alice", []byte("something else"), signer, false)
+	forged.Hash, forged.Pixels = other.Hash, other.Pixels
+	if status, _ := post("/auth/login", forged); status != http.StatusUnauthorized {
+		t.Errorf("login with signature over another message: status %d; want 401", status)
+	}
+
+	// A malformed signature is refused, not a crash
+	truncated := signChallenge("alice")
+	truncated.Pixels = truncated.Pixels[:2]
+	if status, _ := post("/auth/login", truncated); status != http.StatusUnauthorized {
+		t.Errorf("login with truncated signature: status %d; want 401", status)
+	}
+
+	// Presenting a challenge for another DID does not use it up
+	mine := signChallenge("alice")
+	theirs := *mine
+	theirs.DID = "carol"
+	if status, _ := post("/auth/login", &theirs); status != http.StatusUnauthorized {
+		t.Errorf("login with another DID's challenge: status %d; want 401", status)
+	}
+	if status, out := post("/auth/login", mine); status != http.StatusOK {
+		t.Errorf("login after another DID presented the challenge: status %d (%v)", status, out["error"])
+	}
+
+	// A DID registered with a public key cannot log in with the image signature alone
+	combined := &rubix.CombinedSigner{Image: signer, Key: rubix.NewECDSASigner(key)}
+	if status, out := post("/auth/login", signChallengeWith("carol", combined)); status != http.StatusOK {
+		t.Errorf("nlss+ecdsa login: status %d (%v)", status, out["error"])
+	}
+	if status, _ := post("/auth/login", signChallengeWith("carol", signer)); status != http.StatusUnauthorized {
+		t.Errorf("nlss login for a DID with a public key: status %d; want 401", status)
+	}
+
+	// Outstanding challenges per DID are bounded; the oldest goes first
+	first := signChallenge("alice")
+	for i := 0; i < auth.MaxChallengesPerDID; i++ {
+		now = now.Add(time.Millisecond)
+		signChallenge("alice")
+	}
+	if status, _ := post("/auth/login", first); status != http.StatusUnauthorized {
+		t.Errorf("login with a dropped challenge: status %d; want 401", status)
+	}
+
+	// Expired challenges and tokens are rejected
+	late := signChallenge("alice")
+	now = now.Add(auth.DefaultChallengeTTL + time.Second)
+	if status, _ := post("/auth/login", late); status != http.StatusUnauthorized {
+		t.Errorf("login with expired challenge: status %d; want 401", status)
+	}
+	now = now.Add(auth.DefaultSessionTTL)
+	if _, err := srv.VerifyToken(token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("VerifyToken on expired token = %v; want ErrInvalidToken", err)
+	}
+
+	// Tokens signed with another secret are rejected
+	otherSrv, _ := auth.NewServer(reg, []byte("other secret"))
+	if _, err := otherSrv.VerifyToken(token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("VerifyToken with another secret = %v; want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestTransferBundleOfflineFlow(t *testing.T) {
+	shares := generateTestShares(t, "bundle-test")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+	hash := nlss.CalculateSHA3Hash("bundle transaction")
+
+	var submitted rubix.SignatureRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/initiate-rbt-transfer":
+			var resp rubix.InitiateTransferResponse
+			resp.Status = true
+			resp.Result.ID = "req-1"
+			resp.Result.Hash = base64.StdEncoding.EncodeToString([]byte(hash))
+			json.NewEncoder(w).Encode(resp)
+		case "/api/signature-response":
+			json.NewDecoder(r.Body).Decode(&submitted)
+			json.NewEncoder(w).Encode(rubix.SignatureResponse{Status: true, Message: "done"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	nodeURL := strings.TrimPrefix(server.URL, "http://")
+
+	// Prepare
+	params := rubix.TransferParams{RubixNodeURL: nodeURL, SenderDID: "sender", ReceiverDID: "receiver", Amount: 1.5}
+	pending, err := rubix.PrepareTransfer(params)
+	if err != nil {
+		t.Fatalf("PrepareTransfer failed: %v", err)
+	}
+	if pending.RequestID != "req-1" || pending.Hash != hash {
+		t.Fatalf("PrepareTransfer = %+v; want req-1 and the decoded hash", pending)
+	}
+	bundlePath := filepath.Join(dir, "transfer.json")
+	if err := rubix.SaveTransferBundle(bundlePath, rubix.NewTransferBundle(params, pending)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign
+	bundle, err := rubix.LoadTransferBundle(bundlePath)
+	if err != nil {
+		t.Fatalf("LoadTransferBundle failed: %v", err)
+	}
+	sig, err := rubix.SignTransfer(bundle.Hash, &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)})
+	if err != nil {
+		t.Fatalf("SignTransfer failed: %v", err)
+	}
+	bundle.Attach(sig)
+	signedPath := filepath.Join(dir, "transfer.signed.json")
+	if err := rubix.SaveTransferBundle(signedPath, bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	// Submit
+	signed, err := rubix.LoadTransferBundle(signedPath)
+	if err != nil {
+		t.Fatalf("LoadTransferBundle(signed) failed: %v", err)
+	}
+	if _, err := rubix.SubmitTransfer(rubix.NewClient(nodeURL), signed.RequestID, signed.Signature); err != nil {
+		t.Fatalf("SubmitTransfer failed: %v", err)
+	}
+	if submitted.ID != "req-1" {
+		t.Errorf("Submitted request ID = %q; want req-1", submitted.ID)
+	}
+	ok, err := nlss.NlssVerify(filepath.Join(dir, nlss.DIDImageFileName), filepath.Join(dir, nlss.PubShareFileName), hash, submitted.Signature.Pixels)
+	if err != nil || !ok {
+		t.Errorf("Submitted signature does not verify: %v, %v", ok, err)
+	}
+
+	// A bundle edited after preparing, or carrying another request's signature, is rejected
+	tampered := *signed
+	tampered.Amount = 100
+	if err := tampered.Check(); !errors.Is(err, rubix.ErrBundleMismatch) {
+		t.Errorf("Check on edited amount = %v; want ErrBundleMismatch", err)
+	}
+
+	other := rubix.NewTransferBundle(params, &rubix.PendingTransfer{RequestID: "req-2", Hash: hash})
+	other.Signature, other.SignatureBinding = signed.Signature, signed.SignatureBinding
+	if err := other.Check(); !errors.Is(err, rubix.ErrBundleMismatch) {
+		t.Errorf("Check on swapped signature = %v; want ErrBundleMismatch", err)
+	}
+
+	info, err := os.Stat(signedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Signed bundle permissions = %o; want 600", perm)
+	}
+}
@@ -0,0 +1,292 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+	"break-nlss/pkg/simnode"
+)
+
+// fastRetries keeps retry tests quick
+var fastRetries = rubix.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestClientRetriesIdempotentCalls(t *testing.T) {
+	srv, _ := startSimNode(t)
+	client := rubix.NewClient(srv.Addr)
+	client.Retry = fastRetries
+
+	// A node restarting answers 503 twice, then recovers
+	srv.Node.FailNext("/api/get-account-info", http.StatusServiceUnavailable, "restarting")
+	srv.Node.FailNext("/api/get-account-info", http.StatusServiceUnavailable, "restarting")
+	if _, err := client.GetBalance("alice"); err != nil {
+		t.Errorf("GetBalance after two 503s = %v; want success on the third attempt", err)
+	}
+
+	// status:false answers are node decisions and are not retried
+	srv.Node.FailNext("/api/get-account-info", 0, "no such DID")
+	if _, err := client.GetBalance("alice"); err == nil || !strings.Contains(err.Error(), "no such DID") {
+		t.Errorf("GetBalance with status:false = %v; want the node's message", err)
+	}
+
+	// A 503 after the transfer request was sent may mean it was processed: no retry
+	srv.Node.FailNext("/api/initiate-rbt-transfer", http.StatusServiceUnavailable, "restarting")
+	if _, err := client.InitiateTransfer(rubix.InitiateTransferRequest{Sender: "alice", Receiver: "bob", TokenCount: 1, Type: 2}); err == nil {
+		t.Error("InitiateTransfer retried after a 503")
+	}
+	if txs := srv.Node.Transactions(); len(txs) != 0 {
+		t.Errorf("Node saw %d transfers; want 0", len(txs))
+	}
+}
+
+// dialFailures fails the first n requests as if the node refused the connection
+type dialFailures struct {
+	n        int32
+	attempts atomic.Int32
+}
+
+func (d *dialFailures) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d.attempts.Add(1) <= d.n {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientRetriesUnsentTransfers(t *testing.T) {
+	srv, _ := startSimNode(t)
+	client := rubix.NewClient(srv.Addr)
+	client.Retry = fastRetries
+	transport := &dialFailures{n: 2}
+	client.HTTPClient.Transport = transport
+
+	// The request never reached the node, so it is safe to send again
+	if _, err := client.InitiateTransfer(rubix.InitiateTransferRequest{Sender: "alice", Receiver: "bob", TokenCount: 1, Type: 2}); err != nil {
+		t.Fatalf("InitiateTransfer after refused connections = %v; want success", err)
+	}
+	if got := transport.attempts.Load(); got != 3 {
+		t.Errorf("Attempts = %d; want 3", got)
+	}
+	if txs := srv.Node.Transactions(); len(txs) != 1 {
+		t.Errorf("Node saw %d transfers; want exactly 1", len(txs))
+	}
+}
+
+func TestClientTimeoutAndContext(t *testing.T) {
+	var calls atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	client := rubix.NewClient(strings.TrimPrefix(slow.URL, "http://"))
+	client.Timeout = 50 * time.Millisecond
+	client.Retry = fastRetries
+
+	start := time.Now()
+	if _, err := client.GetAllDID(); err == nil {
+		t.Fatal("GetAllDID against a hung node succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetAllDID took %v; want the per-call timeout to apply", elapsed)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Calls = %d; want 3 (first attempt plus 2 retries)", got)
+	}
+
+	// A cancelled context stops the call and its retries
+	client.Timeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls.Store(0)
+	if _, err := client.GetBalanceContext(ctx, "alice"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetBalanceContext = %v; want context.DeadlineExceeded", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Calls after context deadline = %d; want 1", got)
+	}
+}
+
+// writeClientCert creates a self-signed client certificate and returns its
+// certificate and key paths plus the certificate for the server's trust pool
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "break-nlss test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath, cert
+}
+
+func TestClientTLSAndAuthHeaders(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := writeClientCert(t, dir)
+
+	node := simnode.New()
+	node.AddAccount(simnode.Account{DID: "alice", DIDType: rubix.DIDTypeWallet, Balance: 4})
+	api := node.Handler()
+
+	// An HTTPS proxy requiring a client certificate, an API key and a bearer token
+	proxy := httptest.NewUnstartedServer(http.StripPrefix("/rubix", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Node-Key") != "k123" || r.Header.Get("Authorization") != "Bearer t456" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		api.ServeHTTP(w, r)
+	})))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	proxy.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	proxy.StartTLS()
+	defer proxy.Close()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: proxy.Certificate().Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := rubix.ClientOptions{
+		CAFile:       caPath,
+		CertFile:     certPath,
+		KeyFile:      keyPath,
+		APIKey:       "k123",
+		APIKeyHeader: "X-Node-Key",
+		BearerToken:  "t456",
+	}
+	client, err := rubix.NewClientWithOptions(proxy.URL+"/rubix/", opts)
+	if err != nil {
+		t.Fatalf("NewClientWithOptions failed: %v", err)
+	}
+	client.Retry.MaxRetries = 0
+	balance, err := client.GetBalance("alice")
+	if err != nil {
+		t.Fatalf("GetBalance over mutual TLS failed: %v", err)
+	}
+	if balance.AccountInfo[0].RBTAmount != 4 {
+		t.Errorf("Balance = %v; want 4", balance.AccountInfo[0].RBTAmount)
+	}
+
+	// Missing credentials are rejected by the proxy
+	opts.BearerToken = ""
+	client, _ = rubix.NewClientWithOptions(proxy.URL+"/rubix", opts)
+	client.Retry.MaxRetries = 0
+	if _, err := client.GetBalance("alice"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("GetBalance without bearer token = %v; want 401", err)
+	}
+
+	// Without the client certificate the TLS handshake fails
+	opts.CertFile, opts.KeyFile = "", ""
+	client, _ = rubix.NewClientWithOptions(proxy.URL+"/rubix", opts)
+	client.Retry.MaxRetries = 0
+	if _, err := client.GetBalance("alice"); err == nil {
+		t.Error("GetBalance without a client certificate succeeded")
+	}
+
+	if _, err := rubix.NewClientWithOptions(proxy.URL, rubix.ClientOptions{CertFile: certPath}); err == nil {
+		t.Error("NewClientWithOptions accepted a certificate without a key")
+	}
+}
+
+func TestClientFailover(t *testing.T) {
+	a, dir := startSimNode(t)
+	b, _ := startSimNode(t)
+	client := rubix.NewClient(a.Addr + ", " + b.Addr)
+	client.Retry = fastRetries
+	signer := &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+	transfer := func() error {
+		_, err := rubix.TransferTokens(rubix.TransferParams{SenderDID: "alice", ReceiverDID: "bob", Amount: 1, Signer: signer, Client: client})
+		return err
+	}
+
+	// Transfers are spread over the nodes; each is submitted where it was initiated
+	for i := 0; i < 2; i++ {
+		if err := transfer(); err != nil {
+			t.Fatalf("Transfer %d failed: %v", i+1, err)
+		}
+	}
+	for name, srv := range map[string]*simnode.Server{"A": a, "B": b} {
+		txs := srv.Node.Transactions()
+		if len(txs) != 1 || txs[0].Status != simnode.StatusSuccess {
+			t.Errorf("Node %s transactions = %+v; want one successful transfer", name, txs)
+		}
+	}
+
+	// With node A down, reads fail over and transfers go to node B
+	a.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetBalance("alice"); err != nil {
+			t.Errorf("GetBalance with one node down = %v", err)
+		}
+	}
+	if err := transfer(); err != nil {
+		t.Fatalf("Transfer with one node down failed: %v", err)
+	}
+	if txs := b.Node.Transactions(); len(txs) != 2 {
+		t.Errorf("Node B saw %d transfers; want 2", len(txs))
+	}
+
+	// A node answering 5xx to the health check is not picked
+	b.Node.FailNext("/api/node-status", http.StatusServiceUnavailable, "restarting")
+	if _, err := client.Pin(context.Background()); !errors.Is(err, rubix.ErrNetwork) && !errors.Is(err, rubix.ErrNodeUnavailable) {
+		t.Errorf("Pin with no healthy node = %v; want a node error", err)
+	}
+
+	// A bundle records the node the transfer was pinned to
+	pending, err := rubix.PrepareTransfer(rubix.TransferParams{SenderDID: "alice", ReceiverDID: "bob", Amount: 1, Client: client})
+	if err != nil {
+		t.Fatalf("PrepareTransfer failed: %v", err)
+	}
+	if bundle := rubix.NewTransferBundle(rubix.TransferParams{RubixNodeURL: a.Addr + "," + b.Addr}, pending); bundle.RubixNodeURL != b.Addr {
+		t.Errorf("Bundle node = %q; want %q", bundle.RubixNodeURL, b.Addr)
+	}
+}
@@ -1,7 +1,7 @@
 package test
 
 import (
-	"os"
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -22,7 +22,7 @@ func TestCalculateSHA3Hash(t *testing.T) {
 		{
 			name:     "Simple string",
 			input:    "Hello World",
-			expected: "592fa743889fc7f92ac2a37bb1f5ba1daf2a5c84741ca0e0061d243a2e6707ba",
+			expected: "e167f68d6563d75bb25f3aa49c29ef612d41352dc00606de7cbd630bb2665f51",
 		},
 	}
 
@@ -225,32 +225,24 @@ func TestRandomPositions(t *testing.T) {
 	}
 
 	result := crypto.RandomPositions("signer", hash, 32, pvt1)
-
-	// Check that we got the expected keys
-	if _, ok := result["originalPos"]; !ok {
-		t.Error("Result missing 'originalPos' key")
-	}
-
-	if _, ok := result["posForSign"]; !ok {
-		t.Error("Result missing 'posForSign' key")
+	if result == nil {
+		t.Fatal("RandomPositions returned nil")
 	}
 
 	// Check array lengths
-	if len(result["originalPos"]) != 32 {
-		t.Errorf("originalPos length = %d; want 32", len(result["originalPos"]))
+	if len(result.OriginalPos) != 32 {
+		t.Errorf("OriginalPos length = %d; want 32", len(result.OriginalPos))
 	}
 
-	if len(result["posForSign"]) != 256 {
-		t.Errorf("posForSign length = %d; want 256", len(result["posForSign"]))
+	if len(result.PosForSign) != 256 {
+		t.Errorf("PosForSign length = %d; want 256", len(result.PosForSign))
 	}
 
-	// Test the critical formula for the first position
+	// Test the critical formula for the first position (positions are byte-aligned)
 	hashChar := int(hash[0] - '0') // '0' = 0
 	expectedPos := (((2402 + hashChar) * 2709) + ((0 + 2709) + hashChar)) % 2048
-	if result["posForSign"][0] != expectedPos {
-		t.Errorf("First position = %d; want %d (based on critical formula)", result["posForSign"][0], expectedPos)
+	expectedPos = (expectedPos / 8) * 8
+	if result.PosForSign[0] != expectedPos {
+		t.Errorf("First position = %d; want %d (based on critical formula)", result.PosForSign[0], expectedPos)
 	}
 }
-
-// Helper function for testing
-import "fmt"
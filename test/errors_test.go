@@ -0,0 +1,98 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestAPIErrorCategories(t *testing.T) {
+	srv, dir := startSimNode(t)
+	client := rubix.NewClient(srv.Addr)
+	client.Retry.MaxRetries = 0
+
+	expect := func(name string, err, category error) {
+		t.Helper()
+		if !errors.Is(err, category) {
+			t.Errorf("%s: error %v is not %v", name, err, category)
+		}
+	}
+	initiate := func(sender, receiver string, amount float64) error {
+		_, err := client.InitiateTransfer(rubix.InitiateTransferRequest{Sender: sender, Receiver: receiver, TokenCount: amount, Type: 2})
+		return err
+	}
+
+	overBalance := initiate("alice", "bob", 100)
+	expect("over balance", overBalance, rubix.ErrInsufficientBalance)
+	expect("self transfer", initiate("alice", "alice", 1), rubix.ErrInvalidReceiver)
+	expect("unknown sender", initiate("carol", "bob", 1), rubix.ErrNotFound)
+
+	_, err := client.SubmitSignature(rubix.SignatureRequest{ID: "missing"})
+	expect("unknown request", err, rubix.ErrNotFound)
+	_, err = client.GetBalance("carol")
+	expect("unknown DID", err, rubix.ErrNotFound)
+
+	srv.Node.FailNext("/api/getalldid", http.StatusUnauthorized, "no token")
+	_, err = client.GetAllDID()
+	expect("unauthorized", err, rubix.ErrUnauthorized)
+
+	srv.Node.FailNext("/api/getalldid", http.StatusServiceUnavailable, "restarting")
+	_, restarting := client.GetAllDID()
+	expect("node restarting", restarting, rubix.ErrNodeUnavailable)
+
+	// A share of another DID is rejected by the node; the category survives TransferTokens' wrapping
+	other := generateTestShares(t, "errors-other")
+	otherDir := t.TempDir()
+	if err := nlss.SaveShares(other, otherDir); err != nil {
+		t.Fatal(err)
+	}
+	_, err = rubix.TransferTokens(rubix.TransferParams{
+		SenderDID: "alice", ReceiverDID: "bob", Amount: 1, Client: client,
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(otherDir, nlss.PvtShareFileName)},
+	})
+	expect("wrong share", err, rubix.ErrSignatureRejected)
+
+	// The fields describe the failed call
+	var apiErr *rubix.APIError
+	if !errors.As(overBalance, &apiErr) {
+		t.Fatalf("InitiateTransfer error %T is not an *APIError", overBalance)
+	}
+	if apiErr.Endpoint != "/api/initiate-rbt-transfer" || apiErr.StatusCode != http.StatusOK || !strings.Contains(apiErr.Message, "insufficient balance") {
+		t.Errorf("APIError = %+v; want the initiate endpoint, HTTP 200 and the node's message", apiErr)
+	}
+	if !errors.As(restarting, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("APIError for 503 = %+v; want StatusCode 503", apiErr)
+	}
+
+	// The right share still works after the failures
+	_, err = rubix.TransferTokens(rubix.TransferParams{
+		SenderDID: "alice", ReceiverDID: "bob", Amount: 1, Client: client,
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)},
+	})
+	if err != nil {
+		t.Errorf("TransferTokens after failures = %v", err)
+	}
+}
+
+func TestAPIErrorTransportCategories(t *testing.T) {
+	garbage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>gateway page</html>"))
+	}))
+	client := rubix.NewClient(garbage.URL)
+	client.Retry.MaxRetries = 0
+	if _, err := client.GetAllDID(); !errors.Is(err, rubix.ErrMalformedResponse) {
+		t.Errorf("GetAllDID on HTML response = %v; want ErrMalformedResponse", err)
+	}
+
+	// Nothing listens on the address once the server is closed
+	garbage.Close()
+	if _, err := client.GetAllDID(); !errors.Is(err, rubix.ErrNetwork) {
+		t.Errorf("GetAllDID on closed server = %v; want ErrNetwork", err)
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+	"break-nlss/pkg/simnode"
+)
+
+func TestTransactionHistory(t *testing.T) {
+	srv, dir := startSimNode(t)
+	srv.Node.AddTransaction(simnode.Transaction{ID: "tx-refund", SenderDID: "bob", ReceiverDID: "alice", Amount: 3, Comment: "Refund",
+		Time: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)})
+	srv.Node.AddTransaction(simnode.Transaction{ID: "tx-carol", SenderDID: "carol", ReceiverDID: "alice", Amount: 1,
+		Time: time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)})
+	srv.Node.AddTransaction(simnode.Transaction{ID: "tx-failed", SenderDID: "alice", ReceiverDID: "bob", Amount: 9, Status: simnode.StatusFailed})
+
+	_, err := rubix.TransferTokens(rubix.TransferParams{
+		RubixNodeURL: srv.Addr, SenderDID: "alice", ReceiverDID: "bob", Amount: 2, Comment: "rent",
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)},
+	})
+	if err != nil {
+		t.Fatalf("TransferTokens failed: %v", err)
+	}
+
+	client := rubix.NewClient(srv.Addr)
+	history := func(q rubix.HistoryQuery) []string {
+		t.Helper()
+		q.DID = "alice"
+		entries, err := client.TransactionHistory(context.Background(), q)
+		if err != nil {
+			t.Fatalf("TransactionHistory(%+v) failed: %v", q, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Direction+":"+e.Peer+":"+e.Comment)
+		}
+		return got
+	}
+	expect := func(name string, got []string, want ...string) {
+		t.Helper()
+		if !slices.Equal(got, want) {
+			t.Errorf("%s = %v; want %v", name, got, want)
+		}
+	}
+
+	// Oldest first; failed transfers are not part of the history
+	expect("all", history(rubix.HistoryQuery{}), "received:bob:Refund", "received:carol:", "sent:bob:rent")
+	expect("sent", history(rubix.HistoryQuery{Direction: rubix.DirectionSent}), "sent:bob:rent")
+	expect("peer", history(rubix.HistoryQuery{Peer: "bob"}), "received:bob:Refund", "sent:bob:rent")
+	expect("comment", history(rubix.HistoryQuery{Comment: "REF"}), "received:bob:Refund")
+
+	// Date bounds are whole days, both included
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+	expect("January", history(rubix.HistoryQuery{From: day("2025-01-01"), To: day("2025-01-31")}), "received:bob:Refund")
+	expect("up to March 1", history(rubix.HistoryQuery{To: day("2025-03-01")}), "received:bob:Refund", "received:carol:")
+	expect("from March 2", history(rubix.HistoryQuery{From: day("2025-03-02"), Direction: rubix.DirectionReceived}))
+
+	resp, err := client.GetTransactionsByDID(rubix.TransactionQuery{DID: "bob", Role: rubix.RoleReceiver})
+	if err != nil {
+		t.Fatalf("GetTransactionsByDID failed: %v", err)
+	}
+	if len(resp.TxnDetails) != 1 || resp.TxnDetails[0].Amount != 2 || !resp.TxnDetails[0].Status {
+		t.Errorf("Bob received %+v; want the 2 RBT transfer", resp.TxnDetails)
+	}
+}
@@ -0,0 +1,217 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+	"break-nlss/pkg/storage"
+)
+
+func TestTransferLedger(t *testing.T) {
+	srv, dir := startSimNode(t)
+	path := filepath.Join(t.TempDir(), "ledger", "transfers.jsonl")
+	ledger, err := storage.OpenLedger(path)
+	if err != nil {
+		t.Fatalf("OpenLedger failed: %v", err)
+	}
+	defer ledger.Close()
+
+	record := func(r *rubix.TransferResult) error {
+		entry := storage.LedgerEntry{
+			ID: r.ID, IdempotencyKey: r.IdempotencyKey, RequestID: r.RequestID, TransactionID: r.TransactionID, SenderDID: r.SenderDID, ReceiverDID: r.ReceiverDID,
+			Amount: r.Amount, Hash: r.Hash, NodeURL: r.NodeURL, State: r.State, CreatedAt: r.CreatedAt,
+		}
+		if r.Signature != nil {
+			entry.Signature, entry.Pixels = r.Signature.Signature, r.Signature.Pixels
+		}
+		return ledger.Append(entry)
+	}
+	params := rubix.TransferParams{
+		SenderDID: "alice", ReceiverDID: "bob", Client: rubix.NewClient(srv.Addr), OnUpdate: record,
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)},
+	}
+	for _, amount := range []float64{1, 2} {
+		params.Amount = amount
+		if _, err := rubix.TransferTokens(params); err != nil {
+			t.Fatalf("TransferTokens(%v) failed: %v", amount, err)
+		}
+	}
+
+	entries, err := storage.LoadLedger(path)
+	if err != nil {
+		t.Fatalf("LoadLedger failed: %v", err)
+	}
+	var states []string
+	for _, e := range entries[:4] {
+		states = append(states, e.State)
+	}
+	if want := []string{rubix.TransferInitiating, rubix.TransferInitiated, rubix.TransferSigned, rubix.TransferSubmitted}; !slices.Equal(states, want) {
+		t.Errorf("Entries of the first transfer = %v; want %v", states, want)
+	}
+
+	receipts := storage.Receipts(entries)
+	if len(receipts) != 2 {
+		t.Fatalf("Receipts = %d; want one per transfer", len(receipts))
+	}
+	first := receipts[0]
+	if first.Amount != 1 || first.State != rubix.TransferSubmitted || first.TransactionID == "" ||
+		first.Hash == "" || len(first.Pixels) == 0 || first.NodeURL != srv.Addr {
+		t.Errorf("Receipt = %+v; want the submitted 1 RBT transfer with hash, signature and node", first)
+	}
+	if got := storage.FilterReceipts(receipts, storage.ReceiptQuery{TransactionID: receipts[1].TransactionID}); len(got) != 1 || got[0].Amount != 2 {
+		t.Errorf("FilterReceipts by transaction ID = %+v; want the 2 RBT transfer", got)
+	}
+	if got := storage.FilterReceipts(receipts, storage.ReceiptQuery{DID: "carol"}); len(got) != 0 {
+		t.Errorf("FilterReceipts for another DID = %+v; want none", got)
+	}
+
+	// A write cut short by a crash only loses that entry
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"request_id":"cut`)
+	f.Close()
+	if again, err := storage.LoadLedger(path); err != nil || len(again) != len(entries) {
+		t.Errorf("LoadLedger with truncated line = %d entries, %v; want %d", len(again), err, len(entries))
+	}
+}
+
+func TestTransferStopsWhenJournalFails(t *testing.T) {
+	srv, dir := startSimNode(t)
+	client := rubix.NewClient(srv.Addr)
+	errJournal := errors.New("disk full")
+	failOn := func(state string) func(r *rubix.TransferResult) error {
+		return func(r *rubix.TransferResult) error {
+			if r.State == state {
+				return errJournal
+			}
+			return nil
+		}
+	}
+	params := rubix.TransferParams{
+		SenderDID: "alice", ReceiverDID: "bob", Amount: 1, Client: client,
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)},
+	}
+
+	params.OnUpdate = failOn(rubix.TransferInitiating)
+	result, err := rubix.TransferTokens(params)
+	if !errors.Is(err, errJournal) || result.RequestID != "" {
+		t.Errorf("TransferTokens with unjournaled start = %+v, %v; want not initiated", result, err)
+	}
+
+	// The signature never reaches the node, so the request is still waiting for it
+	params.OnUpdate = failOn(rubix.TransferSigned)
+	params.Signer = &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+	result, err = rubix.TransferTokens(params)
+	if !errors.Is(err, errJournal) || result.State != rubix.TransferSigned {
+		t.Fatalf("TransferTokens with unjournaled signature = %+v, %v; want signed but not submitted", result, err)
+	}
+	if _, err := rubix.SubmitTransfer(client, result.RequestID, result.Signature); err != nil {
+		t.Errorf("SubmitTransfer after the aborted transfer failed: %v; want the request still pending", err)
+	}
+}
+
+func TestResumeInterruptedTransfer(t *testing.T) {
+	srv, dir := startSimNode(t)
+	client := rubix.NewClient(srv.Addr)
+	signer := func() rubix.Signer {
+		return &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+	}
+	params := rubix.TransferParams{SenderDID: "alice", ReceiverDID: "bob", Amount: 1, Client: client}
+
+	// The process died after the node issued the request: only the journal knows it
+	interrupted := func() *rubix.TransferResult {
+		t.Helper()
+		result, err := rubix.NewTransferResult(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pending, err := rubix.PrepareTransfer(params)
+		if err != nil {
+			t.Fatalf("PrepareTransfer failed: %v", err)
+		}
+		result.RequestID, result.NodeURL, result.Hash, result.State = pending.RequestID, pending.NodeURL, pending.Hash, rubix.TransferInitiated
+		return result
+	}
+
+	result := interrupted()
+	if !result.Resumable() || result.IdempotencyKey != rubix.TransferKey("alice", "bob", 1, "") {
+		t.Fatalf("Journaled result = %+v; want resumable with the default idempotency key", result)
+	}
+	var states []string
+	resumeParams := params
+	resumeParams.Signer = signer()
+	resumeParams.OnUpdate = func(r *rubix.TransferResult) error {
+		states = append(states, r.State)
+		return nil
+	}
+	resumed, err := rubix.ResumeTransfer(resumeParams, result, false)
+	if err != nil || resumed.State != rubix.TransferSubmitted || resumed.TransactionID == "" {
+		t.Fatalf("ResumeTransfer = %+v, %v; want submitted", resumed, err)
+	}
+	if want := []string{rubix.TransferSigned, rubix.TransferSubmitted}; !slices.Equal(states, want) {
+		t.Errorf("Journaled states = %v; want %v", states, want)
+	}
+
+	// Died after submitting but before journaling it: the signature is not sent again
+	result = interrupted()
+	sig, err := rubix.SignTransfer(result.Hash, signer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.State, result.Signature = rubix.TransferSigned, sig
+	if _, err := rubix.SubmitTransfer(client, result.RequestID, sig); err != nil {
+		t.Fatal(err)
+	}
+	states = nil
+	resumeParams.Signer = nil
+	resumed, err = rubix.ResumeTransfer(resumeParams, result, false)
+	if err != nil || resumed.TransactionID != result.RequestID || !slices.Equal(states, []string{resumed.State}) {
+		t.Errorf("ResumeTransfer of a submitted request = %+v (%v), %v; want the node's outcome without resubmitting", resumed, states, err)
+	}
+	if resumed.Resumable() {
+		t.Errorf("State %s after resuming is still resumable", resumed.State)
+	}
+
+	// A node that cannot tell whether the request was processed gets no signature unless forced
+	result = interrupted()
+	result.State = rubix.TransferSigned
+	if result.Signature, err = rubix.SignTransfer(result.Hash, signer()); err != nil {
+		t.Fatal(err)
+	}
+	srv.Node.FailNext("/api/request-status", 404, "404 page not found")
+	if _, err := rubix.ResumeTransfer(resumeParams, result, false); !errors.Is(err, rubix.ErrStatusUnknown) || result.State != rubix.TransferSigned {
+		t.Errorf("ResumeTransfer with unknown status = %s, %v; want ErrStatusUnknown without submitting", result.State, err)
+	}
+	srv.Node.FailNext("/api/request-status", 404, "404 page not found")
+	if resumed, err := rubix.ResumeTransfer(resumeParams, result, true); err != nil || resumed.State != rubix.TransferSubmitted {
+		t.Errorf("ResumeTransfer with force = %+v, %v; want submitted", resumed, err)
+	}
+
+	// Identical transfers are found while unfinished or recent
+	now := time.Now()
+	key := rubix.TransferKey("alice", "bob", 1, "")
+	receipts := []storage.LedgerEntry{
+		{ID: "old", IdempotencyKey: key, State: rubix.TransferConfirmed, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "stuck", IdempotencyKey: key, State: rubix.TransferInitiated, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "failed", IdempotencyKey: key, State: rubix.TransferFailed, CreatedAt: now},
+		{ID: "other", IdempotencyKey: "other", State: rubix.TransferConfirmed, CreatedAt: now},
+	}
+	var ids []string
+	for _, r := range storage.FindDuplicates(receipts, key, now.Add(-24*time.Hour)) {
+		ids = append(ids, r.ID)
+	}
+	if !slices.Equal(ids, []string{"stuck"}) {
+		t.Errorf("FindDuplicates within 24h = %v; want only the unfinished transfer", ids)
+	}
+	if dups := storage.FindDuplicates(receipts, key, time.Time{}); len(dups) != 2 {
+		t.Errorf("FindDuplicates of any age = %d; want 2", len(dups))
+	}
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"break-nlss/pkg/auth"
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestSignAndVerifyMessage(t *testing.T) {
+	shares := generateTestShares(t, "message-test")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+	didPath := filepath.Join(dir, nlss.DIDImageFileName)
+	pubPath := filepath.Join(dir, nlss.PubShareFileName)
+	image := &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+
+	key, err := crypto.GenerateECKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "pubKey.pem")
+	if err := crypto.SavePublicKeyToPEM(&key.PublicKey, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := crypto.LoadPublicKeyFromPEM(keyPath)
+	if err != nil {
+		t.Fatalf("LoadPublicKeyFromPEM failed: %v", err)
+	}
+
+	message := []byte("login to internal tools")
+	signers := map[string]rubix.Signer{
+		rubix.SignerNLSS:     image,
+		rubix.SignerCombined: &rubix.CombinedSigner{Image: image, Key: rubix.NewECDSASigner(key)},
+	}
+	for kind, signer := range signers {
+		signed, err := auth.SignMessage("did", message, signer, true)
+		if err != nil {
+			t.Fatalf("%s: SignMessage failed: %v", kind, err)
+		}
+		if signed.Signer != kind {
+			t.Errorf("%s: document signer = %q", kind, signed.Signer)
+		}
+
+		// Round trip through the portable document
+		docPath := filepath.Join(dir, kind+".sig.json")
+		if err := auth.SaveSignedMessage(docPath, signed); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := auth.LoadSignedMessage(docPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var key *ecdsa.PublicKey
+		if kind == rubix.SignerCombined {
+			key = pubKey
+		}
+		if err := loaded.Verify(didPath, pubPath, key); err != nil {
+			t.Errorf("%s: Verify failed: %v", kind, err)
+		}
+		if err := loaded.CheckMessage([]byte("something else")); !errors.Is(err, auth.ErrInvalidSignature) {
+			t.Errorf("%s: CheckMessage on another message = %v; want ErrInvalidSignature", kind, err)
+		}
+
+		loaded.Message = "tampered"
+		if err := loaded.Verify(didPath, pubPath, key); !errors.Is(err, auth.ErrInvalidSignature) {
+			t.Errorf("%s: Verify on tampered message = %v; want ErrInvalidSignature", kind, err)
+		}
+	}
+
+	// A verifier holding the DID's key requires the ECDSA part
+	nlssOnly, err := auth.SignMessage("did", message, image, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nlssOnly.Verify(didPath, pubPath, pubKey); !errors.Is(err, auth.ErrInvalidSignature) {
+		t.Errorf("Verify of an nlss document with a public key = %v; want ErrInvalidSignature", err)
+	}
+
+	// Only the signers a document can carry are accepted
+	nlssOnly.Signer = rubix.SignerECDSA
+	if err := nlssOnly.Verify(didPath, pubPath, nil); !errors.Is(err, auth.ErrInvalidSignature) {
+		t.Errorf("Verify with signer %q = %v; want ErrInvalidSignature", nlssOnly.Signer, err)
+	}
+
+	// Another DID's key must not verify the ECDSA part
+	otherKey, _ := crypto.GenerateECKeyPair()
+	signed, err := auth.SignMessage("did", message, signers[rubix.SignerCombined], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signed.Verify(didPath, pubPath, &otherKey.PublicKey); !errors.Is(err, auth.ErrInvalidSignature) {
+		t.Errorf("Verify with another key = %v; want ErrInvalidSignature", err)
+	}
+
+	// A document with a truncated signature is invalid, not a crash
+	signed.Pixels = signed.Pixels[:2]
+	truncatedPath := filepath.Join(dir, "truncated.sig.json")
+	if err := auth.SaveSignedMessage(truncatedPath, signed); err != nil {
+		t.Fatal(err)
+	}
+	truncated, err := auth.LoadSignedMessage(truncatedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := truncated.Verify(didPath, pubPath, pubKey); !errors.Is(err, auth.ErrInvalidSignature) {
+		t.Errorf("Verify with truncated pixels = %v; want ErrInvalidSignature", err)
+	}
+
+	if _, err := auth.SignMessage("did", message, rubix.NewECDSASigner(key), false); err == nil {
+		t.Error("SignMessage accepted a key-only signer")
+	}
+}
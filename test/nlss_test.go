@@ -0,0 +1,343 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"break-nlss/pkg/nlss"
+)
+
+func generateTestShares(t testing.TB, seed string) *nlss.Shares {
+	t.Helper()
+	shares, err := nlss.GenerateDID(nlss.NewSeededReader([]byte(seed)))
+	if err != nil {
+		t.Fatalf("Failed to generate shares: %v", err)
+	}
+	return shares
+}
+
+func TestGenerateDIDShares(t *testing.T) {
+	shares := generateTestShares(t, "generate-test")
+
+	if len(shares.DID) != nlss.DIDImageWidth*nlss.DIDImageHeight*3 {
+		t.Fatalf("DID length = %d; want %d", len(shares.DID), nlss.DIDImageWidth*nlss.DIDImageHeight*3)
+	}
+	if len(shares.Pub) != nlss.ShareImageWidth*nlss.ShareImageHeight*3 {
+		t.Fatalf("Public share length = %d; want %d", len(shares.Pub), nlss.ShareImageWidth*nlss.ShareImageHeight*3)
+	}
+
+	if !nlss.VerifyPVT(shares.DID, shares.Pub, shares.Pvt) {
+		t.Error("VerifyPVT failed for generated shares")
+	}
+
+	if !bytes.Equal(nlss.Combine2Shares(shares.Pvt, shares.Pub), shares.DID) {
+		t.Error("Combine2Shares did not reproduce the DID image")
+	}
+}
+
+func TestGenerateDIDSeeded(t *testing.T) {
+	a := generateTestShares(t, "seed-a")
+	b := generateTestShares(t, "seed-a")
+	c := generateTestShares(t, "seed-b")
+
+	if !bytes.Equal(a.DID, b.DID) || !bytes.Equal(a.Pub, b.Pub) || !bytes.Equal(a.Pvt, b.Pvt) {
+		t.Error("Same seed produced different shares")
+	}
+	if bytes.Equal(a.DID, c.DID) {
+		t.Error("Different seeds produced the same DID image")
+	}
+}
+
+func TestGeneratedSharesSignAndVerify(t *testing.T) {
+	shares := generateTestShares(t, "sign-test")
+	dir := t.TempDir()
+
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+
+	// Shares must survive the PNG round trip unchanged
+	pvt, err := nlss.GetPNGImagePixels(filepath.Join(dir, nlss.PvtShareFileName))
+	if err != nil {
+		t.Fatalf("Failed to read private share: %v", err)
+	}
+	if !bytes.Equal(pvt, shares.Pvt) {
+		t.Fatal("Private share changed after PNG round trip")
+	}
+
+	hash := nlss.CalculateSHA3Hash("test transaction")
+	sig, err := nlss.Sign(filepath.Join(dir, nlss.PvtShareFileName), hash)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if len(sig) != 32 {
+		t.Errorf("Signature length = %d; want 32", len(sig))
+	}
+
+	ok, err := nlss.NlssVerify(filepath.Join(dir, nlss.DIDImageFileName), filepath.Join(dir, nlss.PubShareFileName), hash, sig)
+	if err != nil || !ok {
+		t.Errorf("NlssVerify = %v, %v; want true", ok, err)
+	}
+
+	// A signature for a different hash must not verify
+	ok, _ = nlss.NlssVerify(filepath.Join(dir, nlss.DIDImageFileName), filepath.Join(dir, nlss.PubShareFileName), nlss.CalculateSHA3Hash("other transaction"), sig)
+	if ok {
+		t.Error("NlssVerify accepted a signature for a different hash")
+	}
+}
+
+func TestSignShareMatchesFileSign(t *testing.T) {
+	shares := generateTestShares(t, "in-memory-test")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+	didPath := filepath.Join(dir, nlss.DIDImageFileName)
+	pubPath := filepath.Join(dir, nlss.PubShareFileName)
+
+	pvt, err := nlss.ReconstructFromFiles(didPath, pubPath)
+	if err != nil {
+		t.Fatalf("ReconstructFromFiles failed: %v", err)
+	}
+
+	hash := nlss.CalculateSHA3Hash("in-memory transaction")
+	memSig, err := nlss.SignShare(pvt, hash)
+	if err != nil {
+		t.Fatalf("SignShare failed: %v", err)
+	}
+	fileSig, err := nlss.Sign(filepath.Join(dir, nlss.PvtShareFileName), hash)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	// The reconstructed share may differ from the generated one, but both must sign validly
+	for name, sig := range map[string][]byte{"in-memory": memSig, "file": fileSig} {
+		ok, err := nlss.NlssVerify(didPath, pubPath, hash, sig)
+		if err != nil || !ok {
+			t.Errorf("NlssVerify(%s signature) = %v, %v; want true", name, ok, err)
+		}
+	}
+
+	nlss.Wipe(pvt)
+	for _, b := range pvt {
+		if b != 0 {
+			t.Fatal("Wipe left non-zero bytes in the private share")
+		}
+	}
+}
+
+func TestBreakNLSSGeneratedShares(t *testing.T) {
+	shares := generateTestShares(t, "break-test")
+
+	pvt, err := nlss.BreakNLSS(shares.DID, shares.Pub)
+	if err != nil {
+		t.Fatalf("BreakNLSS failed: %v", err)
+	}
+
+	if !nlss.VerifyPVT(shares.DID, shares.Pub, pvt) {
+		t.Error("Reconstructed private share failed verification")
+	}
+}
+
+func TestBreakNLSSFromFilesPermissions(t *testing.T) {
+	shares := generateTestShares(t, "break-files-test")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// An existing world-readable share is replaced, not reused
+	out := filepath.Join(dir, "out.png")
+	if err := os.WriteFile(out, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := nlss.BreakNLSSFromFiles(filepath.Join(dir, nlss.DIDImageFileName), filepath.Join(dir, nlss.PubShareFileName), out); err != nil {
+		t.Fatalf("BreakNLSSFromFiles failed: %v", err)
+	}
+	info, err := os.Stat(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Private share permissions = %o; want 600", perm)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 4 {
+		t.Errorf("Output directory has %d entries; want the shares and out.png only", len(entries))
+	}
+}
+
+// legacyBreakNLSS is the original bit-string implementation of BreakNLSS,
+// kept as the reference for byte-for-byte comparisons and benchmarks
+func legacyBreakNLSS(didBytes, pubBytes []byte) []byte {
+	didBits := nlss.ConvertToBitString(didBytes)
+	pubBits := nlss.ConvertToBitString(pubBytes)
+	privateBytes := make([]byte, len(pubBytes))
+
+	for i := 0; i < len(didBits); i++ {
+		didBit := didBits[i]
+		temp := ""
+		for k := 8 * i; k < 8*i+8; k++ {
+			sum := (int(didBit-'0') * int(pubBits[k]-'0')) % 2
+			temp = nlss.ConvertString(temp, sum)
+		}
+
+		pvtCandidate := nlss.ConvertBitString(temp)[0]
+		for {
+			cnt := 0
+			for y := pubBytes[i] & pvtCandidate; y != 0; y &= (y - 1) {
+				cnt++
+			}
+			if cnt%2 == int(didBit-'0') {
+				privateBytes[i] = pvtCandidate
+				break
+			}
+			pvtCandidate++
+		}
+	}
+
+	return privateBytes
+}
+
+// legacyVerifyPVT is the original bit-string implementation of VerifyPVT
+func legacyVerifyPVT(didBytes, pubBytes, pvtBytes []byte) bool {
+	didBits := nlss.ConvertToBitString(didBytes)
+	for i := 0; i < len(didBits); i++ {
+		cnt := 0
+		for y := pubBytes[i] & pvtBytes[i]; y != 0; y &= (y - 1) {
+			cnt++
+		}
+		if cnt%2 != int(didBits[i]-'0') {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBreakNLSSMatchesLegacy(t *testing.T) {
+	shares := generateTestShares(t, "legacy-test")
+
+	got, err := nlss.BreakNLSS(shares.DID, shares.Pub)
+	if err != nil {
+		t.Fatalf("BreakNLSS failed: %v", err)
+	}
+
+	want := legacyBreakNLSS(shares.DID, shares.Pub)
+	if !bytes.Equal(got, want) {
+		t.Fatal("BreakNLSS output differs from the legacy implementation")
+	}
+}
+
+func TestVerifyPVTMatchesLegacy(t *testing.T) {
+	shares := generateTestShares(t, "verify-test")
+
+	if got, want := nlss.VerifyPVT(shares.DID, shares.Pub, shares.Pvt), legacyVerifyPVT(shares.DID, shares.Pub, shares.Pvt); got != want {
+		t.Errorf("VerifyPVT = %v; legacy = %v", got, want)
+	}
+
+	// Corrupt one private byte so that its parity flips
+	for i, p := range shares.Pub {
+		if p&1 == 1 {
+			shares.Pvt[i] ^= 1
+			break
+		}
+	}
+	if got, want := nlss.VerifyPVT(shares.DID, shares.Pub, shares.Pvt), legacyVerifyPVT(shares.DID, shares.Pub, shares.Pvt); got != want || got {
+		t.Errorf("VerifyPVT on corrupted share = %v; legacy = %v; want false", got, want)
+	}
+	if err := nlss.CheckPVT(shares.DID, shares.Pub, shares.Pvt); err == nil || !strings.Contains(err.Error(), "mismatch at bit") {
+		t.Errorf("CheckPVT on corrupted share = %v; want the mismatching bit", err)
+	}
+}
+
+func TestCombine2SharesMatchesBitString(t *testing.T) {
+	shares := generateTestShares(t, "combine-test")
+	pvt, pub := shares.Pvt[:4096], shares.Pub[:4096]
+
+	pvtBits := nlss.ConvertToBitString(pvt)
+	pubBits := nlss.ConvertToBitString(pub)
+	want := ""
+	for i := 0; i < len(pvtBits); i += 8 {
+		sum := 0
+		for j := i; j < i+8; j++ {
+			sum += int(pvtBits[j]-'0') * int(pubBits[j]-'0')
+		}
+		want = nlss.ConvertString(want, sum%2)
+	}
+
+	if !bytes.Equal(nlss.Combine2Shares(pvt, pub), nlss.ConvertBitString(want)) {
+		t.Error("Combine2Shares differs from the bit-string computation")
+	}
+
+	if nlss.Combine2Shares(pvt[:12], pub[:12]) != nil {
+		t.Error("Combine2Shares should return nil for lengths that are not a multiple of 8")
+	}
+}
+
+func TestBreakNLSSIntoAllocationFree(t *testing.T) {
+	shares := generateTestShares(t, "alloc-test")
+	dst := make([]byte, len(shares.Pub))
+
+	allocs := testing.AllocsPerRun(5, func() {
+		if err := nlss.BreakNLSSInto(dst, shares.DID, shares.Pub); err != nil {
+			t.Fatal(err)
+		}
+		if !nlss.VerifyPVT(shares.DID, shares.Pub, dst) {
+			t.Fatal("reconstructed share failed verification")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("BreakNLSSInto + VerifyPVT allocated %.0f times; want 0", allocs)
+	}
+}
+
+func TestBreakNLSSZeroPublicByte(t *testing.T) {
+	// A set DID bit cannot be carried by a zero public byte
+	did := []byte{0x80}
+	pub := make([]byte, 8)
+
+	if _, err := nlss.BreakNLSS(did, pub); err == nil {
+		t.Error("BreakNLSS should fail when a set DID bit meets a zero public byte")
+	}
+}
+
+func BenchmarkBreakNLSS(b *testing.B) {
+	shares := generateTestShares(b, "bench")
+	dst := make([]byte, len(shares.Pub))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := nlss.BreakNLSSInto(dst, shares.DID, shares.Pub); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBreakNLSSLegacy(b *testing.B) {
+	shares := generateTestShares(b, "bench")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyBreakNLSS(shares.DID, shares.Pub)
+	}
+}
+
+func BenchmarkVerifyPVT(b *testing.B) {
+	shares := generateTestShares(b, "bench")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nlss.VerifyPVT(shares.DID, shares.Pub, shares.Pvt)
+	}
+}
+
+func BenchmarkVerifyPVTLegacy(b *testing.B) {
+	shares := generateTestShares(b, "bench")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyVerifyPVT(shares.DID, shares.Pub, shares.Pvt)
+	}
+}
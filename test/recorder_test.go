@@ -0,0 +1,110 @@
+package test
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	srv, dir := startSimNode(t)
+	fixture := filepath.Join(t.TempDir(), "transfer.json")
+	signer := &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+
+	// The same calls run once against the node while recording and once from the fixture
+	run := func(rec *rubix.Recorder) (float64, string) {
+		t.Helper()
+		client := rubix.NewClient(srv.Addr)
+		client.HTTPClient.Transport = rec
+
+		initiated, err := client.InitiateTransfer(rubix.InitiateTransferRequest{Sender: "alice", Receiver: "bob", TokenCount: 2, Type: 2})
+		if err != nil {
+			t.Fatalf("InitiateTransfer failed: %v", err)
+		}
+		hash, err := base64.StdEncoding.DecodeString(initiated.Result.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := rubix.SignTransfer(string(hash), signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.SubmitSignature(rubix.SignatureRequest{ID: initiated.Result.ID, Signature: *sig})
+		if err != nil {
+			t.Fatalf("SubmitSignature failed: %v", err)
+		}
+		balance, err := client.GetBalance("alice")
+		if err != nil {
+			t.Fatalf("GetBalance failed: %v", err)
+		}
+		return balance.AccountInfo[0].RBTAmount, resp.Message
+	}
+
+	rec, err := rubix.NewRecorder(fixture, rubix.RecordMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordedBalance, recordedMessage := run(rec)
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// No node is needed to replay
+	srv.Close()
+	replay, err := rubix.NewRecorder(fixture, rubix.ReplayMode)
+	if err != nil {
+		t.Fatalf("NewRecorder(replay) failed: %v", err)
+	}
+	balance, message := run(replay)
+	if balance != recordedBalance || message != recordedMessage {
+		t.Errorf("Replay = %v, %q; want %v, %q", balance, message, recordedBalance, recordedMessage)
+	}
+	if unused := replay.Unused(); len(unused) != 0 {
+		t.Errorf("Unused interactions after replay: %+v", unused)
+	}
+
+	// Requests that were never recorded fail instead of reaching a node
+	client := rubix.NewClient(srv.Addr)
+	client.HTTPClient.Transport = replay
+	client.Retry.MaxRetries = 0
+	if _, err := client.GetBalance("bob"); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("GetBalance for unrecorded request = %v; want no recorded interaction", err)
+	}
+}
+
+func TestReplayNodeErrors(t *testing.T) {
+	rec, err := rubix.NewRecorder(filepath.Join("testdata", "rubix", "node_errors.json"), rubix.ReplayMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rubix.NewClient("rubix-node.invalid:20006")
+	client.HTTPClient.Transport = rec
+
+	if _, err := client.GetBalance("bafybmimissing"); err == nil || !strings.Contains(err.Error(), "Failed to get account info") {
+		t.Errorf("GetBalance for missing DID = %v; want the node's message", err)
+	}
+
+	sender := "bafybmiguvjk5nqxjmrdhfna42dzpgloy47d7r3vncsax6nxe3irir4vkdy"
+	balance, err := client.GetBalance(sender)
+	if err != nil {
+		t.Fatalf("GetBalance failed: %v", err)
+	}
+	if info := balance.AccountInfo[0]; info.RBTAmount != 67 || info.DIDType != rubix.DIDTypeLite {
+		t.Errorf("GetBalance = %+v; want 67 RBT on a lite DID", info)
+	}
+
+	_, err = client.InitiateTransfer(rubix.InitiateTransferRequest{
+		Receiver: "bafybmiee3dmi25jxpev4rwjli23yxndihsqcayvtxwy2pa6vz4qs2no64u", Sender: sender, TokenCount: 100, Type: 2,
+	})
+	if err == nil || !strings.Contains(err.Error(), "Insufficient balance") {
+		t.Errorf("InitiateTransfer over balance = %v; want Insufficient balance", err)
+	}
+
+	if _, err := client.SubmitSignature(rubix.SignatureRequest{ID: "txn-expired", Signature: rubix.SignatureData{Pixels: []byte{0, 0, 0}}}); err == nil {
+		t.Error("SubmitSignature on a 500 response succeeded")
+	}
+}
@@ -0,0 +1,171 @@
+package test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestSigners(t *testing.T) {
+	shares := generateTestShares(t, "signer-test")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+	didPath := filepath.Join(dir, nlss.DIDImageFileName)
+	pubPath := filepath.Join(dir, nlss.PubShareFileName)
+	hash := nlss.CalculateSHA3Hash("signer transaction")
+
+	key, err := crypto.GenerateECKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "pvtKey.pem")
+	if err := crypto.SavePrivateKeyToPEM(key, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	keySigner, err := rubix.NewECDSASignerFromPEM(keyPath, "")
+	if err != nil {
+		t.Fatalf("NewECDSASignerFromPEM failed: %v", err)
+	}
+
+	memSigner, err := rubix.NewReconstructedSigner(didPath, pubPath)
+	if err != nil {
+		t.Fatalf("NewReconstructedSigner failed: %v", err)
+	}
+
+	signers := map[string]rubix.Signer{
+		"file":     &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)},
+		"memory":   memSigner,
+		"ecdsa":    keySigner,
+		"combined": &rubix.CombinedSigner{Image: memSigner, Key: keySigner},
+	}
+	for name, signer := range signers {
+		sig, err := signer.Sign(hash)
+		if err != nil {
+			t.Errorf("%s: Sign failed: %v", name, err)
+			continue
+		}
+
+		if name != "ecdsa" {
+			ok, err := nlss.NlssVerify(didPath, pubPath, hash, sig.Pixels)
+			if err != nil || !ok {
+				t.Errorf("%s: NlssVerify = %v, %v; want true", name, ok, err)
+			}
+		} else if sig.Pixels != nil {
+			t.Errorf("%s: unexpected image signature", name)
+		}
+
+		var signed []byte
+		switch name {
+		case "ecdsa":
+			signed = []byte(hash)
+		case "combined":
+			// The key signs the hex SHA3-256 of the image signature's bit string
+			signed = []byte(crypto.HexToStr(crypto.CalculateHash([]byte(nlss.ConvertToBitString(sig.Pixels)), "SHA3-256")))
+		default:
+			if sig.Signature != nil {
+				t.Errorf("%s: unexpected ECDSA signature", name)
+			}
+			continue
+		}
+		ok, err := crypto.VerifyECDSASignature(&key.PublicKey, signed, sig.Signature)
+		if err != nil || !ok {
+			t.Errorf("%s: VerifyECDSASignature = %v, %v; want true", name, ok, err)
+		}
+	}
+
+	// Closing wipes the in-memory share
+	if err := rubix.CloseSigner(memSigner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memSigner.Sign(hash); err == nil {
+		t.Error("Sign succeeded after the share was wiped")
+	}
+
+	if _, err := rubix.ParseSignerKind("rsa"); err == nil {
+		t.Error("ParseSignerKind accepted an unknown signer")
+	}
+}
+
+func TestSignerKindForDIDType(t *testing.T) {
+	tests := map[int]string{
+		rubix.DIDTypeBasic:    rubix.SignerCombined,
+		rubix.DIDTypeStandard: rubix.SignerCombined,
+		rubix.DIDTypeWallet:   rubix.SignerNLSS,
+		rubix.DIDTypeChild:    rubix.SignerCombined,
+		rubix.DIDTypeLite:     rubix.SignerECDSA,
+	}
+	for didType, want := range tests {
+		got, err := rubix.SignerKindForDIDType(didType)
+		if err != nil || got != want {
+			t.Errorf("SignerKindForDIDType(%s) = %q, %v; want %q", rubix.DIDTypeName(didType), got, err, want)
+		}
+	}
+
+	if _, err := rubix.SignerKindForDIDType(9); err == nil {
+		t.Error("SignerKindForDIDType accepted an unknown DID type")
+	}
+}
+
+func TestVerifyPixelsNamesBrokenShare(t *testing.T) {
+	shares := generateTestShares(t, "verify-pixels-test")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+	didPath := filepath.Join(dir, nlss.DIDImageFileName)
+	pubPath := filepath.Join(dir, nlss.PubShareFileName)
+	hash := nlss.CalculateSHA3Hash("verify pixels transaction")
+
+	good := &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+	sig, err := good.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rubix.VerifyPixels(didPath, pubPath, hash, sig, rubix.ShareSource(good)); err != nil {
+		t.Errorf("VerifyPixels rejected a valid signature: %v", err)
+	}
+
+	// A private share from another DID signs, but does not verify
+	brokenPath := filepath.Join(dir, "broken.png")
+	other := generateTestShares(t, "other-did")
+	if err := nlss.CreatePNGImage(other.Pvt, nlss.ShareImageWidth, nlss.ShareImageHeight, brokenPath); err != nil {
+		t.Fatal(err)
+	}
+	broken := &rubix.NLSSFileSigner{PvtSharePath: brokenPath}
+	sig, err = broken.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rubix.VerifyPixels(didPath, pubPath, hash, sig, rubix.ShareSource(broken))
+	if !errors.Is(err, rubix.ErrSignatureMismatch) {
+		t.Fatalf("VerifyPixels = %v; want ErrSignatureMismatch", err)
+	}
+	if !strings.Contains(err.Error(), brokenPath) {
+		t.Errorf("Error %q does not name the broken share %s", err, brokenPath)
+	}
+
+	// A truncated signature or a malformed hash is an error, not a panic
+	for name, tc := range map[string]struct {
+		hash   string
+		pixels []byte
+	}{
+		"truncated signature": {hash, sig.Pixels[:2]},
+		"short hash":          {hash[:16], sig.Pixels},
+		"non-hex hash":        {strings.Repeat("z", 64), sig.Pixels},
+	} {
+		err := rubix.VerifyPixels(didPath, pubPath, tc.hash, &rubix.SignatureData{Pixels: tc.pixels}, "bundle.json")
+		if !errors.Is(err, rubix.ErrSignatureMismatch) || !strings.Contains(err.Error(), "bundle.json") {
+			t.Errorf("VerifyPixels with %s = %v; want ErrSignatureMismatch naming the source", name, err)
+		}
+		if ok, err := nlss.NlssVerify(didPath, pubPath, tc.hash, tc.pixels); ok || !errors.Is(err, nlss.ErrMalformedSignature) {
+			t.Errorf("NlssVerify with %s = %v, %v; want ErrMalformedSignature", name, ok, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+	"break-nlss/pkg/simnode"
+)
+
+// startSimNode serves a simulated node with a wallet DID "alice" holding
+// 10 RBT and an empty DID "bob", and returns the node and alice's share directory
+func startSimNode(t *testing.T) (*simnode.Server, string) {
+	t.Helper()
+	shares := generateTestShares(t, "simnode-test")
+	dir := t.TempDir()
+	if err := nlss.SaveShares(shares, dir); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+
+	node := simnode.New()
+	accounts := []simnode.Account{
+		{
+			DID:      "alice",
+			DIDType:  rubix.DIDTypeWallet,
+			Balance:  10,
+			DIDImage: filepath.Join(dir, nlss.DIDImageFileName),
+			PubShare: filepath.Join(dir, nlss.PubShareFileName),
+		},
+		{DID: "bob", DIDType: rubix.DIDTypeWallet},
+	}
+	for _, a := range accounts {
+		if err := node.AddAccount(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv, err := simnode.Start(node, "")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv, dir
+}
+
+func TestSimNodeTransfer(t *testing.T) {
+	srv, dir := startSimNode(t)
+	signer := &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+
+	params := rubix.TransferParams{RubixNodeURL: srv.Addr, SenderDID: "alice", ReceiverDID: "bob", Amount: 2.5, Signer: signer}
+	if _, err := rubix.TransferTokens(params); err != nil {
+		t.Fatalf("TransferTokens failed: %v", err)
+	}
+
+	for did, want := range map[string]float64{"alice": 7.5, "bob": 2.5} {
+		balance, err := rubix.GetAccountBalance(srv.Addr, did)
+		if err != nil {
+			t.Fatalf("GetAccountBalance(%s) failed: %v", did, err)
+		}
+		if balance != want {
+			t.Errorf("%s balance = %v; want %v", did, balance, want)
+		}
+	}
+
+	all, err := rubix.NewClient(srv.Addr).GetAllDID()
+	if err != nil {
+		t.Fatalf("GetAllDID failed: %v", err)
+	}
+	if len(all.AccountInfo) != 2 || all.AccountInfo[0].DID != "alice" {
+		t.Errorf("GetAllDID = %+v; want alice and bob", all.AccountInfo)
+	}
+
+	txs := srv.Node.Transactions()
+	if len(txs) != 1 || txs[0].Status != simnode.StatusSuccess {
+		t.Errorf("Transactions = %+v; want one successful transfer", txs)
+	}
+}
+
+func TestSimNodeRejectsBadTransfers(t *testing.T) {
+	srv, dir := startSimNode(t)
+
+	// A share that does not belong to alice fails at the node and unlocks the tokens
+	other := generateTestShares(t, "simnode-other")
+	otherDir := t.TempDir()
+	if err := nlss.SaveShares(other, otherDir); err != nil {
+		t.Fatal(err)
+	}
+	params := rubix.TransferParams{
+		RubixNodeURL: srv.Addr, SenderDID: "alice", ReceiverDID: "bob", Amount: 1,
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(otherDir, nlss.PvtShareFileName)},
+	}
+	if _, err := rubix.TransferTokens(params); err == nil {
+		t.Error("TransferTokens with another DID's share succeeded")
+	}
+	if a, _ := srv.Node.Account("alice"); a.Balance != 10 || a.Locked != 0 {
+		t.Errorf("alice after failed transfer: balance %v, locked %v; want 10 and 0", a.Balance, a.Locked)
+	}
+
+	// A truncated signature fails the transfer and unlocks the tokens
+	pending, err := rubix.PrepareTransfer(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rubix.SubmitTransfer(rubix.NewClient(srv.Addr), pending.RequestID, &rubix.SignatureData{Pixels: []byte{1, 2}}); err == nil {
+		t.Error("SubmitTransfer with a truncated signature succeeded")
+	}
+	if a, _ := srv.Node.Account("alice"); a.Balance != 10 || a.Locked != 0 {
+		t.Errorf("alice after truncated signature: balance %v, locked %v; want 10 and 0", a.Balance, a.Locked)
+	}
+
+	// Overspending is refused at initiation
+	params.Signer = &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)}
+	params.Amount = 50
+	if _, err := rubix.TransferTokens(params); err == nil || !strings.Contains(err.Error(), "insufficient balance") {
+		t.Errorf("TransferTokens over balance = %v; want insufficient balance", err)
+	}
+
+	// Injected failures surface as node errors
+	srv.Node.FailNext("/api/get-account-info", 0, "node busy")
+	if _, err := rubix.GetAccountBalance(srv.Addr, "alice"); err == nil || !strings.Contains(err.Error(), "node busy") {
+		t.Errorf("GetAccountBalance with injected failure = %v; want node busy", err)
+	}
+	if _, err := rubix.GetAccountBalance(srv.Addr, "carol"); err == nil {
+		t.Error("GetAccountBalance for unknown DID succeeded")
+	}
+}
+
+func TestSimNodeLiteDID(t *testing.T) {
+	key, err := crypto.GenerateECKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := simnode.New()
+	node.AddAccount(simnode.Account{DID: "lite", DIDType: rubix.DIDTypeLite, Balance: 3, PubKey: &key.PublicKey})
+	node.AddAccount(simnode.Account{DID: "bob", DIDType: rubix.DIDTypeWallet})
+	srv, err := simnode.Start(node, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	params := rubix.TransferParams{RubixNodeURL: srv.Addr, SenderDID: "lite", ReceiverDID: "bob", Amount: 1, Signer: rubix.NewECDSASigner(key)}
+	if _, err := rubix.TransferTokens(params); err != nil {
+		t.Fatalf("TransferTokens from lite DID failed: %v", err)
+	}
+	if a, _ := node.Account("bob"); a.Balance != 1 {
+		t.Errorf("bob balance = %v; want 1", a.Balance)
+	}
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"break-nlss/pkg/nlss"
+	"break-nlss/pkg/rubix"
+)
+
+func TestTransferStatusAndWait(t *testing.T) {
+	srv, dir := startSimNode(t)
+	srv.Node.ConfirmDelay = 200 * time.Millisecond
+	client := rubix.NewClient(srv.Addr)
+	params := rubix.TransferParams{
+		SenderDID: "alice", ReceiverDID: "bob", Amount: 1, Client: client, PollInterval: 20 * time.Millisecond,
+		Signer: &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(dir, nlss.PvtShareFileName)},
+	}
+	ctx := context.Background()
+
+	// Without waiting, the result only says the node accepted the signature
+	result, err := rubix.TransferTokens(params)
+	if err != nil {
+		t.Fatalf("TransferTokens failed: %v", err)
+	}
+	if result.State != rubix.TransferSubmitted || result.TransactionID == "" || result.NodeURL != srv.Addr {
+		t.Fatalf("Result = %+v; want submitted with transaction ID and node", result)
+	}
+
+	st, err := client.TransferStatus(ctx, result.RequestID, "")
+	if err != nil {
+		t.Fatalf("TransferStatus by request ID failed: %v", err)
+	}
+	if st.State != rubix.TransferPending || st.TransactionID != result.TransactionID {
+		t.Errorf("Status before confirmation = %+v; want pending with the transaction ID", st)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	st, err = client.WaitForTransfer(waitCtx, "", result.TransactionID, 20*time.Millisecond)
+	if err != nil || st.State != rubix.TransferConfirmed || st.Details == nil || st.Details.Amount != 1 {
+		t.Fatalf("WaitForTransfer = %+v, %v; want confirmed 1 RBT transfer", st, err)
+	}
+
+	// TransferTokens waits when asked to
+	params.Wait = 2 * time.Second
+	if result, err = rubix.TransferTokens(params); err != nil || result.State != rubix.TransferConfirmed {
+		t.Errorf("TransferTokens with Wait = %+v, %v; want confirmed", result, err)
+	}
+
+	// A wait that ends first leaves the transfer pending
+	srv.Node.ConfirmDelay = time.Hour
+	params.Wait = 100 * time.Millisecond
+	result, err = rubix.TransferTokens(params)
+	if !errors.Is(err, rubix.ErrTransferPending) || result.State != rubix.TransferPending {
+		t.Errorf("TransferTokens with short Wait = %+v, %v; want pending", result, err)
+	}
+
+	// Rejected signatures are final failures
+	other := generateTestShares(t, "status-other")
+	otherDir := t.TempDir()
+	if err := nlss.SaveShares(other, otherDir); err != nil {
+		t.Fatal(err)
+	}
+	params.Signer = &rubix.NLSSFileSigner{PvtSharePath: filepath.Join(otherDir, nlss.PvtShareFileName)}
+	result, err = rubix.TransferTokens(params)
+	if err == nil || result.State != rubix.TransferFailed || result.Error == "" {
+		t.Fatalf("TransferTokens with wrong share = %+v, %v; want failed", result, err)
+	}
+	if st, err := client.TransferStatus(ctx, result.RequestID, ""); err != nil || st.State != rubix.TransferFailed {
+		t.Errorf("Status of rejected transfer = %+v, %v; want failed", st, err)
+	}
+
+	// Nodes without request status are asked for the transaction instead
+	srv.Node.FailNext("/api/request-status", 404, "404 page not found")
+	if st, err := client.TransferStatus(ctx, result.RequestID, ""); err != nil || st.State != rubix.TransferFailed || st.Unknown {
+		t.Errorf("Status without the request status endpoint = %+v, %v; want failed from the transaction lookup", st, err)
+	}
+	if st, err := client.TransferStatus(ctx, "no-such-request", ""); err != nil || st.State != rubix.TransferPending || !st.Unknown {
+		t.Errorf("TransferStatus of unknown request = %+v, %v; want pending and unknown", st, err)
+	}
+
+	// The result file round-trips for scripts
+	path := filepath.Join(t.TempDir(), "result.json")
+	if err := rubix.SaveTransferResult(path, result); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := rubix.LoadTransferResult(path)
+	if err != nil || loaded.RequestID != result.RequestID || loaded.State != rubix.TransferFailed {
+		t.Errorf("LoadTransferResult = %+v, %v; want the saved result", loaded, err)
+	}
+}
+
+func TestParseTransactionID(t *testing.T) {
+	msg := "Transfer finished successfully in 2.016s with trnxid 4f1c9e0a27b3"
+	if got := rubix.ParseTransactionID(msg); got != "4f1c9e0a27b3" {
+		t.Errorf("ParseTransactionID = %q; want 4f1c9e0a27b3", got)
+	}
+	if got := rubix.ParseTransactionID("Transfer finished successfully"); got != "" {
+		t.Errorf("ParseTransactionID without ID = %q; want empty", got)
+	}
+}
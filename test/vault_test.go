@@ -0,0 +1,84 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"break-nlss/pkg/crypto"
+	"break-nlss/pkg/vault"
+)
+
+func TestSealUnSealRoundTrip(t *testing.T) {
+	data := []byte("private share bytes")
+
+	sealed, err := crypto.Seal("secret", data)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, data) {
+		t.Error("Sealed data contains the plaintext")
+	}
+
+	opened, err := crypto.UnSeal("secret", sealed)
+	if err != nil || !bytes.Equal(opened, data) {
+		t.Errorf("UnSeal = %q, %v; want %q", opened, err, data)
+	}
+
+	if _, err := crypto.UnSeal("wrong", sealed); err == nil {
+		t.Error("UnSeal accepted a wrong password")
+	}
+}
+
+func TestVaultStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	shares := generateTestShares(t, "vault-test")
+	did := "bafybmitestvault"
+
+	v, err := vault.Open(dir, "secret")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if v.Exists(did) {
+		t.Fatal("Exists reported a share before Store")
+	}
+	if _, err := v.Load(did); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("Load of missing share = %v; want ErrNotFound", err)
+	}
+
+	if err := v.Store(did, shares.Pvt); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	info, err := os.Stat(v.Path(did))
+	if err != nil {
+		t.Fatalf("Vault entry missing: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Vault entry permissions = %o; want 600", perm)
+	}
+
+	loaded, err := v.Load(did)
+	if err != nil || !bytes.Equal(loaded, shares.Pvt) {
+		t.Fatalf("Load did not return the stored share (err %v)", err)
+	}
+
+	// The vault is bound to its first password
+	if _, err := vault.Open(dir, "other"); !errors.Is(err, vault.ErrWrongPassword) {
+		t.Errorf("Open with wrong password = %v; want ErrWrongPassword", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "vault.key")
+	if err := os.WriteFile(keyFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	v2, err := vault.OpenWithKeyFile(dir, keyFile)
+	if err != nil {
+		t.Fatalf("OpenWithKeyFile failed: %v", err)
+	}
+	if loaded, err := v2.Load(did); err != nil || !bytes.Equal(loaded, shares.Pvt) {
+		t.Errorf("Load through key file did not return the stored share (err %v)", err)
+	}
+}